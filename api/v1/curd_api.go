@@ -3,14 +3,72 @@ package v1
 import (
 	"demo-curd/dto/request"
 	"demo-curd/dto/response"
+	"demo-curd/i18n"
 	"demo-curd/service"
 	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
 
 type CurdV1Api struct {
-	CurdService *service.CurdService
+	CurdService       *service.CurdService
+	CurdImportService *service.CurdImportService
+	CurdExportService *service.CurdExportService
+	CurdSearchService *service.CurdSearchService
+	I18n              *i18n.I18n
+}
+
+// List
+// @Summary List curds
+// @Description List curds matching the filters, page by page
+// @Tags CURD
+// @Security ApiKeyAuth
+// @Param name query string false "name contains"
+// @Param email query string false "email contains"
+// @Param phone query string false "phone contains"
+// @Param city query string false "city equals"
+// @Param page query int false "page, from 1"
+// @Param size query int false "page size"
+// @Param sort query string false "e.g. name asc, created_at desc"
+// @Success 200 {object} response.PageDTO{items=[]response.CurdDTO}
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd [get]
+func (r *CurdV1Api) List(c *gin.Context) {
+	var filter request.CurdFilterDTO
+	util.Must(c.BindQuery(&filter))
+	res, err := r.CurdService.List(c.Request.Context(), filter, ctxutil.GetPageFromCtx(c))
+	util.Must(err)
+	c.Header(constant.HeaderCacheControl, constant.CacheControlRevalidate)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Get
+// @Summary Get a curd
+// @Description Get a curd, Last-Modified is its updated_at: send it back in If-Modified-Since to get a 304 while unchanged
+// @Tags CURD
+// @Security ApiKeyAuth
+// @Param id path int true "curd id"
+// @Param If-Modified-Since header string false "Last-Modified of the cached copy"
+// @Success 200 {object} response.CurdDTO
+// @Success 304 "not modified since If-Modified-Since"
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/{id} [get]
+func (r *CurdV1Api) Get(c *gin.Context) {
+	id := idParam(c)
+	res, err := r.CurdService.Get(c.Request.Context(), id)
+	util.Must(err)
+	if notModified(c, res.UpdatedAt) {
+		return
+	}
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
 }
 
 // Create
@@ -19,14 +77,175 @@ type CurdV1Api struct {
 // @Tags CURD
 // @Accept json
 // @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "retries with the same key get the response of the first request"
 // @Param body body request.CurdDTO true "JSON body"
 // @Success 200 {object} response.CurdDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
 // @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
 // @Router /api/v1/curd [post]
 func (r *CurdV1Api) Create(c *gin.Context) {
 	var curdDTO request.CurdDTO
 	util.Must(c.BindJSON(&curdDTO))
-	res, err := r.CurdService.Create(&curdDTO)
+	res, err := r.CurdService.Create(c.Request.Context(), &curdDTO)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Patch
+// @Summary Patch a curd
+// @Description Change some fields of a curd with a JSON Merge Patch (application/merge-patch+json, e.g. {"phone": "0912345678", "city": null}) or a JSON Patch (application/json-patch+json, e.g. [{"op": "replace", "path": "/name", "value": "Nam"}]). The result is validated as a whole, only the changed fields are written
+// @Tags CURD
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Security ApiKeyAuth
+// @Param id path int true "curd id"
+// @Param body body object true "merge patch or json patch"
+// @Success 200 {object} response.CurdDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 404 {object} response.Response
+// @Failure 415 {object} response.Response
+// @Failure 422 {object} response.Response "malformed patch or failed test operation"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/{id} [patch]
+func (r *CurdV1Api) Patch(c *gin.Context) {
+	id := idParam(c)
+	patch, err := c.GetRawData()
+	util.Must(err)
+	res, err := r.CurdService.Patch(c.Request.Context(), id, c.ContentType(), patch)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// BulkCreate
+// @Summary Create curds in bulk
+// @Description Create up to 5000 curds, mode all_or_nothing (default) writes nothing when an item fails, mode partial writes the valid items
+// @Tags CURD
+// @Accept json
+// @Security ApiKeyAuth
+// @Param mode query string false "all_or_nothing or partial"
+// @Param Idempotency-Key header string false "retries with the same key get the response of the first request"
+// @Param body body []request.CurdDTO true "JSON body"
+// @Success 200 {object} response.BulkResult
+// @Success 207 {object} response.BulkResult
+// @Failure 400 {object} response.Response "VALIDATION_ERROR when mode is unknown"
+// @Failure 422 {object} response.BulkResult
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/bulk [post]
+func (r *CurdV1Api) BulkCreate(c *gin.Context) {
+	mode, err := request.ParseBulkMode(c.Query("mode"))
+	util.Must(err)
+	var curdDTOs []request.CurdDTO
+	util.Must(c.BindJSON(&curdDTOs))
+	res, err := r.CurdService.BulkCreate(c.Request.Context(), curdDTOs, mode)
+	util.Must(err)
+	r.localizeBulkResult(c, res)
+	c.JSON(bulkStatus(res, mode), response.Response{
+		Data: res,
+	})
+}
+
+// BulkUpdate
+// @Summary Update curds in bulk
+// @Description Update up to 5000 curds identified by id, see BulkCreate for the modes
+// @Tags CURD
+// @Accept json
+// @Security ApiKeyAuth
+// @Param mode query string false "all_or_nothing or partial"
+// @Param body body []request.CurdBulkUpdateDTO true "JSON body"
+// @Success 200 {object} response.BulkResult
+// @Success 207 {object} response.BulkResult
+// @Failure 400 {object} response.Response "VALIDATION_ERROR when mode is unknown"
+// @Failure 422 {object} response.BulkResult
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/bulk [put]
+func (r *CurdV1Api) BulkUpdate(c *gin.Context) {
+	mode, err := request.ParseBulkMode(c.Query("mode"))
+	util.Must(err)
+	var curdDTOs []request.CurdBulkUpdateDTO
+	util.Must(c.BindJSON(&curdDTOs))
+	res, err := r.CurdService.BulkUpdate(c.Request.Context(), curdDTOs, mode)
+	util.Must(err)
+	r.localizeBulkResult(c, res)
+	c.JSON(bulkStatus(res, mode), response.Response{
+		Data: res,
+	})
+}
+
+// BulkDelete
+// @Summary Delete curds in bulk
+// @Description Delete up to 5000 curds by id, see BulkCreate for the modes
+// @Tags CURD
+// @Accept json
+// @Security ApiKeyAuth
+// @Param mode query string false "all_or_nothing or partial"
+// @Param body body []uint64 true "JSON array of ids"
+// @Success 200 {object} response.BulkResult
+// @Success 207 {object} response.BulkResult
+// @Failure 400 {object} response.Response "VALIDATION_ERROR when mode is unknown"
+// @Failure 422 {object} response.BulkResult
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/bulk [delete]
+func (r *CurdV1Api) BulkDelete(c *gin.Context) {
+	mode, err := request.ParseBulkMode(c.Query("mode"))
+	util.Must(err)
+	var ids []uint64
+	util.Must(c.BindJSON(&ids))
+	res, err := r.CurdService.BulkDelete(c.Request.Context(), ids, mode)
+	util.Must(err)
+	r.localizeBulkResult(c, res)
+	c.JSON(bulkStatus(res, mode), response.Response{
+		Data: res,
+	})
+}
+
+// messages of the failed bulk items by error code, the database errors are left as is
+var bulkItemMessages = map[string]string{
+	constant.ErrorCodeValidation: "error.validation",
+	constant.ErrorCodeNotFound:   "error.not_found",
+	constant.ErrorCodeSkipped:    "error.bulk_skipped",
+}
+
+// localizeBulkResult translates the errors of the failed items in the request language.
+func (r *CurdV1Api) localizeBulkResult(c *gin.Context, res *response.BulkResult) {
+	lang := ctxutil.GetLangFromCtx(c.Request.Context())
+	for i, item := range res.Items {
+		if msgId, ok := bulkItemMessages[item.ErrorCode]; ok {
+			res.Items[i].ErrorMessage = r.I18n.Localize(lang, &i18n.Message{ID: msgId, Other: item.ErrorMessage}, nil, nil)
+		}
+		r.I18n.LocalizeErrorFields(lang, item.ErrorFields)
+	}
+}
+
+// bulkStatus is 200 when every item succeeded, 207 when a partial request wrote some
+// items and 422 when nothing was written.
+func bulkStatus(res *response.BulkResult, mode request.BulkMode) int {
+	if res.Failed == 0 {
+		return http.StatusOK
+	}
+	if mode == request.BulkModePartial && res.Succeeded > 0 {
+		return http.StatusMultiStatus
+	}
+	return http.StatusUnprocessableEntity
+}
+
+// Search
+// @Summary Search curds
+// @Description Full-text search over name, email, phone and city, ignoring case and diacritics ("Ha Noi" matches "Hà Nội"), best matches first. The matched fragments are returned in highlights
+// @Tags CURD
+// @Security ApiKeyAuth
+// @Param q query string true "words to search, each must match"
+// @Param page query int false "page, from 1"
+// @Param size query int false "page size"
+// @Success 200 {object} response.PageDTO{items=[]response.CurdSearchHitDTO}
+// @Failure 400 {object} response.Response "VALIDATION_ERROR when q has no word"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/search [get]
+func (r *CurdV1Api) Search(c *gin.Context) {
+	res, err := r.CurdSearchService.Search(c.Request.Context(), c.Query("q"), ctxutil.GetPageFromCtx(c))
 	util.Must(err)
 	c.JSON(http.StatusOK, response.Response{
 		Data: res,
@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"demo-curd/dto/request"
+	"demo-curd/util"
+	"demo-curd/util/ctxutil"
+	"demo-curd/util/sheetutil"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"time"
+)
+
+// Export
+// @Summary Export curds
+// @Description Stream the curds matching the list filters as csv, ndjson or xlsx, the headers are localized with Accept-Language
+// @Tags CURD
+// @Produce text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security ApiKeyAuth
+// @Param format query string false "csv (default), ndjson or xlsx"
+// @Param name query string false "name contains"
+// @Param email query string false "email contains"
+// @Param phone query string false "phone contains"
+// @Param city query string false "city equals"
+// @Param sort query string false "e.g. name asc, created_at desc"
+// @Param Accept-Language header string false "e.g. vi"
+// @Success 200 {file} file
+// @Failure 400 {object} response.Response "VALIDATION_ERROR on the format or the sort"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/export [get]
+func (r *CurdV1Api) Export(c *gin.Context) {
+	format, err := sheetutil.ParseFormat(c.Query("format"))
+	if err != nil {
+		util.Must(validation.Errors{"format": validation.ErrInInvalid})
+	}
+	var filter request.CurdFilterDTO
+	util.Must(c.BindQuery(&filter))
+	sort := c.DefaultQuery("sort", "id")
+	util.Must(r.CurdExportService.CheckExport(sort))
+
+	fileName := fmt.Sprintf("curd-%v.%v", time.Now().Format("20060102150405"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Status(http.StatusOK)
+	// the status is already sent, a failure can only be logged and cut the response short
+	err = r.CurdExportService.Export(c.Request.Context(), c.Writer, format, ctxutil.GetLangFromCtx(c.Request.Context()), filter, sort)
+	if err != nil {
+		log.Ctx(c.Request.Context()).Error().Err(err).Msg("Export curd failed")
+		c.Abort()
+	}
+}
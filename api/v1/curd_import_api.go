@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"demo-curd/dto/response"
+	"demo-curd/service"
+	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Import
+// @Summary Import curds from a csv or xlsx file
+// @Description The first row is the header. Rows are validated and inserted by a background job, poll the returned import for progress. An import is only visible to the user who uploaded the file
+// @Tags CURD
+// @Accept multipart/form-data
+// @Security ApiKeyAuth
+// @Param file formData file true "csv or xlsx file, at most 50MB"
+// @Param mapping formData string false "JSON object mapping column headers to curd fields, e.g. {\"Họ tên\": \"name\"}, other columns are mapped by header name"
+// @Param dry_run formData bool false "validate the rows without inserting them"
+// @Success 202 {object} response.ImportJobDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR on the file, the mapping or dry_run"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/import [post]
+func (r *CurdV1Api) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, constant.ImportMaxFileSize+1<<20)
+	file, err := c.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) {
+		util.Must(validation.Errors{"file": validation.ErrRequired})
+	}
+	util.Must(err)
+	if file.Size > constant.ImportMaxFileSize {
+		util.Must(validation.Errors{"file": service.ErrFileTooLarge.SetParams(map[string]interface{}{"max": constant.ImportMaxFileSize})})
+	}
+	var mapping map[string]string
+	if value := c.PostForm("mapping"); value != "" {
+		if err = json.Unmarshal([]byte(value), &mapping); err != nil {
+			util.Must(validation.Errors{"mapping": service.ErrMappingInvalid})
+		}
+	}
+	dryRun := false
+	if value := c.PostForm("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			util.Must(validation.Errors{"dry_run": validation.ErrInInvalid})
+		}
+	}
+
+	upload, err := os.CreateTemp(r.CurdImportService.Dir, "upload-*"+filepath.Ext(file.Filename))
+	util.Must(err)
+	util.Must(upload.Close())
+	if err := c.SaveUploadedFile(file, upload.Name()); err != nil {
+		os.Remove(upload.Name())
+		util.Must(err)
+	}
+	res, err := r.CurdImportService.Start(c.Request.Context(), file.Filename, upload.Name(), mapping, dryRun)
+	if err != nil {
+		os.Remove(upload.Name())
+		util.Must(err)
+	}
+	r.localizeImport(c, res)
+	c.JSON(http.StatusAccepted, response.Response{
+		Data: res,
+	})
+}
+
+// GetImport
+// @Summary Get an import job
+// @Description Get the status and counters of an import job
+// @Tags CURD
+// @Security ApiKeyAuth
+// @Param id path string true "job id"
+// @Success 200 {object} response.ImportJobDTO
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/import/{id} [get]
+func (r *CurdV1Api) GetImport(c *gin.Context) {
+	res, err := r.CurdImportService.Get(c.Request.Context(), c.Param("id"))
+	util.Must(err)
+	r.localizeImport(c, res)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// GetImportReport
+// @Summary Download the error report of an import job
+// @Description csv of the rejected rows with their errors, available once the job is finished
+// @Tags CURD
+// @Produce text/csv
+// @Security ApiKeyAuth
+// @Param id path string true "job id"
+// @Success 200 {file} file
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/curd/import/{id}/report [get]
+func (r *CurdV1Api) GetImportReport(c *gin.Context) {
+	path, err := r.CurdImportService.ReportPath(c.Request.Context(), c.Param("id"))
+	util.Must(err)
+	c.FileAttachment(path, fmt.Sprintf("import-%v-errors.csv", c.Param("id")))
+}
+
+// localizeImport sets the label of the status of job in the request language.
+func (r *CurdV1Api) localizeImport(c *gin.Context, job *response.ImportJobDTO) {
+	job.StatusLabel = r.I18n.LocalizeEnum(ctxutil.GetLangFromCtx(c.Request.Context()), "import.status", job.Status)
+}
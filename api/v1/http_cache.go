@@ -0,0 +1,26 @@
+package v1
+
+import (
+	"demo-curd/util/constant"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// notModified sets the cache headers of a resource last modified at modifiedAt and
+// answers 304 when the client copy, sent in If-Modified-Since, is still current.
+func notModified(c *gin.Context, modifiedAt time.Time) bool {
+	c.Header(constant.HeaderCacheControl, constant.CacheControlRevalidate)
+	if modifiedAt.IsZero() {
+		return false
+	}
+	// http dates have a second precision
+	modifiedAt = modifiedAt.Truncate(time.Second)
+	c.Header(constant.HeaderLastModified, modifiedAt.UTC().Format(http.TimeFormat))
+	since, err := http.ParseTime(c.GetHeader(constant.HeaderIfModifiedSince))
+	if err != nil || modifiedAt.After(since) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
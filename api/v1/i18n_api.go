@@ -0,0 +1,174 @@
+package v1
+
+import (
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/i18n"
+	"demo-curd/service"
+	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type I18nV1Api struct {
+	I18n               *i18n.I18n
+	TranslationService *service.TranslationService
+}
+
+// Missing
+// @Summary List the missing messages
+// @Description List, by language, the ids of the messages translated in another language but not in this one, the complete languages are left out
+// @Tags I18N
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string][]string
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/i18n/missing [get]
+func (r *I18nV1Api) Missing(c *gin.Context) {
+	c.JSON(http.StatusOK, response.Response{
+		Data: r.I18n.Missing(),
+	})
+}
+
+// List
+// @Summary List the translations edited by the admins
+// @Tags I18N
+// @Security ApiKeyAuth
+// @Param lang query string false "language, e.g. vi"
+// @Param message_id query string false "message id, e.g. error.not_found"
+// @Param page query int false "page, from 1"
+// @Param size query int false "page size"
+// @Param sort query string false "e.g. updated_at desc"
+// @Success 200 {object} response.PageDTO{items=[]response.TranslationDTO}
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/i18n/messages [get]
+func (r *I18nV1Api) List(c *gin.Context) {
+	var filter request.TranslationFilterDTO
+	util.Must(c.BindQuery(&filter))
+	res, err := r.TranslationService.List(c.Request.Context(), filter, ctxutil.GetPageFromCtx(c))
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Create
+// @Summary Translate a message
+// @Description Replace the message of the files for a language, every instance uses it within i18n.refreshInterval. The texts are go templates, set one for the messages depending on a count
+// @Tags I18N
+// @Accept json
+// @Security ApiKeyAuth
+// @Param body body request.TranslationDTO true "JSON body"
+// @Success 200 {object} response.TranslationDTO
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/i18n/messages [post]
+func (r *I18nV1Api) Create(c *gin.Context) {
+	var dto request.TranslationDTO
+	util.Must(c.BindJSON(&dto))
+	res, err := r.TranslationService.Create(c.Request.Context(), &dto)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Get
+// @Summary Get a translation
+// @Tags I18N
+// @Security ApiKeyAuth
+// @Param id path int true "translation id"
+// @Success 200 {object} response.TranslationDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/i18n/messages/{id} [get]
+func (r *I18nV1Api) Get(c *gin.Context) {
+	id := idParam(c)
+	res, err := r.TranslationService.Get(c.Request.Context(), id)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Update
+// @Summary Update a translation
+// @Tags I18N
+// @Accept json
+// @Security ApiKeyAuth
+// @Param id path int true "translation id"
+// @Param body body request.TranslationDTO true "JSON body"
+// @Success 200 {object} response.TranslationDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/i18n/messages/{id} [put]
+func (r *I18nV1Api) Update(c *gin.Context) {
+	id := idParam(c)
+	var dto request.TranslationDTO
+	util.Must(c.BindJSON(&dto))
+	res, err := r.TranslationService.Update(c.Request.Context(), id, &dto)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Delete
+// @Summary Delete a translation
+// @Description The message of the files is used again
+// @Tags I18N
+// @Security ApiKeyAuth
+// @Param id path int true "translation id"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/i18n/messages/{id} [delete]
+func (r *I18nV1Api) Delete(c *gin.Context) {
+	id := idParam(c)
+	util.Must(r.TranslationService.Delete(c.Request.Context(), id))
+	c.JSON(http.StatusOK, response.Response{})
+}
+
+// Export
+// @Summary Export the messages of a language
+// @Description Download the messages used by the app, the edited ones included, as a messages.<lang>.json file
+// @Tags I18N
+// @Produce json
+// @Security ApiKeyAuth
+// @Param lang path string true "language, e.g. vi"
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/i18n/export/{lang} [get]
+func (r *I18nV1Api) Export(c *gin.Context) {
+	lang := c.Param("lang")
+	messages, err := r.TranslationService.Export(lang)
+	util.Must(err)
+	data, err := json.MarshalIndent(messages, "", "  ")
+	util.Must(err)
+	fileName := fmt.Sprintf("%v.%v.json", constant.I18nMessage, lang)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// Import
+// @Summary Import the messages of a language
+// @Description Upload a messages.<lang>.json file as the body, the messages differing from the ones used by the app are saved as translations, all of them or none
+// @Tags I18N
+// @Accept json
+// @Security ApiKeyAuth
+// @Param lang path string true "language, e.g. vi"
+// @Param body body map[string]interface{} true "messages.<lang>.json"
+// @Success 200 {object} response.TranslationImportDTO
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/i18n/import/{lang} [post]
+func (r *I18nV1Api) Import(c *gin.Context) {
+	data, err := c.GetRawData()
+	util.Must(err)
+	res, err := r.TranslationService.Import(c.Request.Context(), c.Param("lang"), data)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
@@ -0,0 +1,108 @@
+package v1
+
+import (
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/i18n"
+	"demo-curd/service"
+	"demo-curd/util"
+	"demo-curd/util/ctxutil"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type JobV1Api struct {
+	JobService *service.JobService
+	I18n       *i18n.I18n
+}
+
+// List
+// @Summary List background jobs
+// @Description List the jobs of the queue, page by page
+// @Tags JOB
+// @Security ApiKeyAuth
+// @Param type query string false "job type"
+// @Param status query string false "pending, running, done, failed or cancelled"
+// @Param page query int false "page, from 1"
+// @Param size query int false "page size"
+// @Param sort query string false "e.g. run_at desc"
+// @Success 200 {object} response.PageDTO{items=[]response.JobDTO}
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/jobs [get]
+func (r *JobV1Api) List(c *gin.Context) {
+	var filter request.JobFilterDTO
+	util.Must(c.BindQuery(&filter))
+	res, err := r.JobService.List(c.Request.Context(), filter, ctxutil.GetPageFromCtx(c))
+	util.Must(err)
+	jobs := res.Items.([]response.JobDTO)
+	for i := range jobs {
+		r.localizeJob(c, &jobs[i])
+	}
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Get
+// @Summary Get a background job
+// @Tags JOB
+// @Security ApiKeyAuth
+// @Param id path int true "job id"
+// @Success 200 {object} response.JobDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/jobs/{id} [get]
+func (r *JobV1Api) Get(c *gin.Context) {
+	id := idParam(c)
+	res, err := r.JobService.Get(c.Request.Context(), id)
+	util.Must(err)
+	r.localizeJob(c, res)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Retry
+// @Summary Retry a failed or cancelled job
+// @Tags JOB
+// @Security ApiKeyAuth
+// @Param id path int true "job id"
+// @Success 200 {object} response.JobDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 409 {object} response.Response "CONFLICT when the status of the job doesn't allow it"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/jobs/{id}/retry [post]
+func (r *JobV1Api) Retry(c *gin.Context) {
+	id := idParam(c)
+	res, err := r.JobService.Retry(c.Request.Context(), id)
+	util.Must(err)
+	r.localizeJob(c, res)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Cancel
+// @Summary Cancel a pending or running job
+// @Tags JOB
+// @Security ApiKeyAuth
+// @Param id path int true "job id"
+// @Success 200 {object} response.JobDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 409 {object} response.Response "CONFLICT when the status of the job doesn't allow it"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/admin/jobs/{id}/cancel [post]
+func (r *JobV1Api) Cancel(c *gin.Context) {
+	id := idParam(c)
+	res, err := r.JobService.Cancel(c.Request.Context(), id)
+	util.Must(err)
+	r.localizeJob(c, res)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// localizeJob sets the label of the status of job in the request language.
+func (r *JobV1Api) localizeJob(c *gin.Context, job *response.JobDTO) {
+	job.StatusLabel = r.I18n.LocalizeEnum(ctxutil.GetLangFromCtx(c.Request.Context()), "job.status", job.Status)
+}
@@ -0,0 +1,17 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"strconv"
+)
+
+// idParam returns the id of the path, a malformed id is a validation error of the id
+// field, rendered as 400 by router.ErrorMiddleware.
+func idParam(c *gin.Context) uint64 {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		panic(validation.Errors{"id": validation.ErrInInvalid})
+	}
+	return id
+}
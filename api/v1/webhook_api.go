@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/service"
+	"demo-curd/util"
+	"demo-curd/util/ctxutil"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type WebhookV1Api struct {
+	WebhookService *service.WebhookService
+}
+
+// Create
+// @Summary Create a webhook subscription
+// @Description Subscribe an URL to curd.created, curd.updated, curd.deleted or * events of the tenant. Deliveries are signed in the X-Webhook-Signature header, "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">", the secret is generated when empty and only returned here
+// @Tags WEBHOOK
+// @Accept json
+// @Security ApiKeyAuth
+// @Param body body request.WebhookSubscriptionDTO true "JSON body"
+// @Success 200 {object} response.WebhookSubscriptionDTO
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/webhooks [post]
+func (r *WebhookV1Api) Create(c *gin.Context) {
+	var dto request.WebhookSubscriptionDTO
+	util.Must(c.BindJSON(&dto))
+	res, err := r.WebhookService.Create(c.Request.Context(), &dto)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// List
+// @Summary List webhook subscriptions
+// @Tags WEBHOOK
+// @Security ApiKeyAuth
+// @Param page query int false "page, from 1"
+// @Param size query int false "page size"
+// @Param sort query string false "e.g. created_at desc"
+// @Success 200 {object} response.PageDTO{items=[]response.WebhookSubscriptionDTO}
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/webhooks [get]
+func (r *WebhookV1Api) List(c *gin.Context) {
+	res, err := r.WebhookService.List(c.Request.Context(), ctxutil.GetPageFromCtx(c))
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Get
+// @Summary Get a webhook subscription
+// @Tags WEBHOOK
+// @Security ApiKeyAuth
+// @Param id path int true "subscription id"
+// @Success 200 {object} response.WebhookSubscriptionDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/webhooks/{id} [get]
+func (r *WebhookV1Api) Get(c *gin.Context) {
+	id := idParam(c)
+	res, err := r.WebhookService.Get(c.Request.Context(), id)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Update
+// @Summary Update a webhook subscription
+// @Description The secret is kept when empty, set active to true to enable a subscription disabled after failed deliveries
+// @Tags WEBHOOK
+// @Accept json
+// @Security ApiKeyAuth
+// @Param id path int true "subscription id"
+// @Param body body request.WebhookSubscriptionDTO true "JSON body"
+// @Success 200 {object} response.WebhookSubscriptionDTO
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/webhooks/{id} [put]
+func (r *WebhookV1Api) Update(c *gin.Context) {
+	id := idParam(c)
+	var dto request.WebhookSubscriptionDTO
+	util.Must(c.BindJSON(&dto))
+	res, err := r.WebhookService.Update(c.Request.Context(), id, &dto)
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
+
+// Delete
+// @Summary Delete a webhook subscription
+// @Tags WEBHOOK
+// @Security ApiKeyAuth
+// @Param id path int true "subscription id"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/webhooks/{id} [delete]
+func (r *WebhookV1Api) Delete(c *gin.Context) {
+	id := idParam(c)
+	util.Must(r.WebhookService.Delete(c.Request.Context(), id))
+	c.JSON(http.StatusOK, response.Response{})
+}
+
+// Deliveries
+// @Summary List the deliveries of a webhook subscription
+// @Description One log per delivery attempt, latest first
+// @Tags WEBHOOK
+// @Security ApiKeyAuth
+// @Param id path int true "subscription id"
+// @Param page query int false "page, from 1"
+// @Param size query int false "page size"
+// @Success 200 {object} response.PageDTO{items=[]response.WebhookDeliveryDTO}
+// @Failure 400 {object} response.Response "VALIDATION_ERROR with the localized error_fields"
+// @Failure 500 {object} interface{} "{"error_code": "<Mã lỗi>", "error_msg": "<Nội dung lỗi>"}"
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (r *WebhookV1Api) Deliveries(c *gin.Context) {
+	id := idParam(c)
+	res, err := r.WebhookService.Deliveries(c.Request.Context(), id, ctxutil.GetPageFromCtx(c))
+	util.Must(err)
+	c.JSON(http.StatusOK, response.Response{
+		Data: res,
+	})
+}
@@ -0,0 +1,751 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"demo-curd/dto/response"
+	"demo-curd/job"
+	"demo-curd/model"
+	"demo-curd/router"
+	"demo-curd/util/constant"
+	"demo-curd/util/dbutil"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// testApp is the app on a fresh sqlite database migrated with the embedded
+// migrations, requests go through the gin engine without a server.
+type testApp struct {
+	App
+	t *testing.T
+	// of company 1 with the authorities given to newTestApp, see login
+	token string
+}
+
+func newTestApp(t *testing.T, authorities ...string) *testApp {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv(constant.EnvKey, "local")
+	t.Setenv("APP_DATABASE_DRIVER", "sqlite")
+	t.Setenv("APP_DATABASE_DBNAME", filepath.Join(t.TempDir(), "test.db"))
+	t.Setenv("APP_DATABASE_MIGRATEONSTART", "true")
+	t.Setenv("APP_DATABASE_AUTOMIGRATE", "false")
+	t.Setenv("APP_IMPORT_DIR", t.TempDir())
+	app, err := InitApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = app.Database.Close()
+	})
+	app.SetupRouters()
+	if err = app.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	a := &testApp{App: app, t: t}
+	a.login(1, authorities...)
+	return a
+}
+
+// login signs the token of the following requests for user 1 of companyId, nil for a
+// user without company, with the secret of the config.
+func (a *testApp) login(companyId interface{}, authorities ...string) {
+	a.t.Helper()
+	a.loginAs(1, companyId, authorities...)
+}
+
+// loginAs is login for another user than 1.
+func (a *testApp) loginAs(userId interface{}, companyId interface{}, authorities ...string) {
+	a.t.Helper()
+	if authorities == nil {
+		authorities = []string{}
+	}
+	claims := jwt.MapClaims{
+		router.JWT_USER_ID:     userId,
+		router.JWT_AUTHORITIES: authorities,
+	}
+	if companyId != nil {
+		claims[constant.COMPANY_ID] = companyId
+	}
+	mw := *a.Router.AuthMiddleware
+	mw.PayloadFunc = func(data interface{}) jwt.MapClaims {
+		return data.(jwt.MapClaims)
+	}
+	token, _, err := mw.TokenGenerator(claims)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	a.token = token
+}
+
+// do sends a request with the token, the body is marshalled unless it's a string.
+func (a *testApp) do(method string, path string, contentType string, body interface{}) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var data []byte
+	switch b := body.(type) {
+	case nil:
+	case string:
+		data = []byte(b)
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	a.Router.Engine.ServeHTTP(w, req)
+	return w
+}
+
+// decode checks the status of w and decodes the data of its body into data.
+func (a *testApp) decode(w *httptest.ResponseRecorder, status int, data interface{}) {
+	a.t.Helper()
+	if w.Code != status {
+		a.t.Fatalf("status %v, want %v: %v", w.Code, status, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response.Response{Data: data}); err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+func TestCurdApi(t *testing.T) {
+	app := newTestApp(t)
+
+	var created response.CurdDTO
+	app.decode(app.do(http.MethodPost, "/api/v1/curd", "application/json",
+		map[string]string{"name": "Nam", "email": "nam@example.com", "phone": "0912345678", "city": "Hà Nội"}),
+		http.StatusOK, &created)
+	if created.Id == 0 || created.Phone != "+84912345678" {
+		t.Fatalf("created %+v", created)
+	}
+
+	var got response.CurdDTO
+	app.decode(app.do(http.MethodGet, fmt.Sprintf("/api/v1/curd/%v", created.Id), "", nil), http.StatusOK, &got)
+	if got.Email != created.Email {
+		t.Fatalf("got %+v, want %+v", got, created)
+	}
+
+	var patched response.CurdDTO
+	app.decode(app.do(http.MethodPatch, fmt.Sprintf("/api/v1/curd/%v", created.Id), "application/merge-patch+json",
+		`{"name": "Nam Nguyen", "city": null}`), http.StatusOK, &patched)
+	if patched.Name != "Nam Nguyen" || patched.City != "" || patched.Email != created.Email {
+		t.Fatalf("patched %+v", patched)
+	}
+
+	var items []response.CurdDTO
+	app.decode(app.do(http.MethodGet, "/api/v1/curd?name=nguyen", "", nil), http.StatusOK, &response.PageDTO{Items: &items})
+	if len(items) != 1 || items[0].Name != "Nam Nguyen" {
+		t.Fatalf("listed %+v", items)
+	}
+
+	w := app.do(http.MethodGet, "/api/v1/curd/999", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("get of a missing curd: %v %v", w.Code, w.Body)
+	}
+	w = app.do(http.MethodPost, "/api/v1/curd", "application/json", map[string]string{"name": "", "email": "nam@example.com"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid create: %v %v", w.Code, w.Body)
+	}
+}
+
+func TestInternalErrorsHideDetails(t *testing.T) {
+	app := newTestApp(t)
+	w := app.do(http.MethodPost, "/api/v1/curd/bulk", "application/json", make([]map[string]string, constant.BulkMaxItems+1))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), strconv.Itoa(constant.BulkMaxItems)) {
+		t.Fatalf("bulk of too many items: %v %v", w.Code, w.Body)
+	}
+
+	if err := app.Database.DB.Exec("DROP TABLE curd").Error; err != nil {
+		t.Fatal(err)
+	}
+	var res response.Response
+	w = app.do(http.MethodGet, "/api/v1/curd/1", "", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusInternalServerError || res.ErrorCode != constant.ErrorCodeInternal || res.ErrorMessage != "Internal server error" {
+		t.Fatalf("failed query: %v %v", w.Code, w.Body)
+	}
+}
+
+func TestMalformedIdsAreRejected(t *testing.T) {
+	app := newTestApp(t, constant.RoleAdmin)
+	for _, path := range []string{"/api/v1/curd/abc", "/api/v1/curd/-1", "/api/v1/webhooks/1x", "/api/v1/admin/jobs/abc", "/api/v1/admin/i18n/messages/abc"} {
+		w := app.do(http.MethodGet, path, "", nil)
+		var res response.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusBadRequest || len(res.ErrorFields) != 1 || res.ErrorFields[0].Field != "id" {
+			t.Fatalf("%v: %v %v", path, w.Code, w.Body)
+		}
+	}
+}
+
+func TestCurdBulkApiRejectsUnknownMode(t *testing.T) {
+	app := newTestApp(t)
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		w := app.do(method, "/api/v1/curd/bulk?mode=bogus", "application/json", "[]")
+		var res response.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusBadRequest || len(res.ErrorFields) != 1 || res.ErrorFields[0].Field != "mode" {
+			t.Fatalf("bulk %v with mode=bogus: %v %v", method, w.Code, w.Body)
+		}
+	}
+}
+
+func TestCurdImportApiErrors(t *testing.T) {
+	app := newTestApp(t)
+
+	w := app.do(http.MethodGet, "/api/v1/curd/import/unknown", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("get of an unknown import: %v %v", w.Code, w.Body)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "curds.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte("name\nNam\n"))
+	_ = form.Close()
+	w = app.do(http.MethodPost, "/api/v1/curd/import", form.FormDataContentType(), body.String())
+	var res response.Response
+	if err = json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || len(res.ErrorFields) != 1 || res.ErrorFields[0].Field != "file" {
+		t.Fatalf("import of a txt file: %v %v", w.Code, w.Body)
+	}
+}
+
+func TestCurdExportApiErrors(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, query := range []string{"format=pdf", "sort=password", "sort=name%20up"} {
+		w := app.do(http.MethodGet, "/api/v1/curd/export?"+query, "", nil)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("export with %v: %v %v", query, w.Code, w.Body)
+		}
+	}
+}
+
+func TestAdminApiRequiresAdminRole(t *testing.T) {
+	for _, c := range []struct {
+		authorities []string
+		status      int
+	}{
+		{nil, http.StatusForbidden},
+		{[]string{"USER"}, http.StatusForbidden},
+		{[]string{constant.RoleAdmin}, http.StatusOK},
+	} {
+		app := newTestApp(t, c.authorities...)
+		for _, path := range []string{"/api/v1/admin/jobs", "/api/v1/admin/i18n/messages", "/api/v1/admin/i18n/missing"} {
+			if w := app.do(http.MethodGet, path, "", nil); w.Code != c.status {
+				t.Fatalf("%v with authorities %v: %v %v, want %v", path, c.authorities, w.Code, w.Body, c.status)
+			}
+		}
+	}
+}
+
+func TestJobApiConflicts(t *testing.T) {
+	app := newTestApp(t, constant.RoleAdmin)
+	queued, err := app.JobManager.Enqueue(context.Background(), job.CleanupType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/v1/admin/jobs/%v", queued.Id)
+
+	var res response.Response
+	w := app.do(http.MethodPost, path+"/retry", "", nil)
+	if err = json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusConflict || res.ErrorCode != constant.ErrorCodeConflict {
+		t.Fatalf("retry of a pending job: %v %v", w.Code, w.Body)
+	}
+	if w = app.do(http.MethodPost, path+"/cancel", "", nil); w.Code != http.StatusOK {
+		t.Fatalf("cancel of a pending job: %v %v", w.Code, w.Body)
+	}
+	if w = app.do(http.MethodPost, path+"/cancel", "", nil); w.Code != http.StatusConflict {
+		t.Fatalf("cancel of a cancelled job: %v %v", w.Code, w.Body)
+	}
+}
+
+func TestGlobalWebhooksRequireAdminRole(t *testing.T) {
+	app := newTestApp(t)
+	subscription := map[string]interface{}{"url": "https://partner.example.com/hooks", "event_types": []string{"*"}}
+
+	var created response.WebhookSubscriptionDTO
+	app.decode(app.do(http.MethodPost, "/api/v1/webhooks", "application/json", subscription), http.StatusOK, &created)
+	if created.TenantId != "1" {
+		t.Fatalf("created %+v", created)
+	}
+
+	app.login(nil)
+	for _, path := range []string{"/api/v1/webhooks", fmt.Sprintf("/api/v1/webhooks/%v", created.Id)} {
+		if w := app.do(http.MethodGet, path, "", nil); w.Code != http.StatusForbidden {
+			t.Fatalf("get %v without company: %v %v", path, w.Code, w.Body)
+		}
+	}
+	if w := app.do(http.MethodPost, "/api/v1/webhooks", "application/json", subscription); w.Code != http.StatusForbidden {
+		t.Fatalf("global subscription of a user: %v %v", w.Code, w.Body)
+	}
+
+	app.login(nil, constant.RoleAdmin)
+	var global response.WebhookSubscriptionDTO
+	app.decode(app.do(http.MethodPost, "/api/v1/webhooks", "application/json", subscription), http.StatusOK, &global)
+	if global.Id == 0 || global.TenantId != "" {
+		t.Fatalf("global subscription %+v", global)
+	}
+}
+
+// importCurds uploads a csv file and waits for its import, the job manager must be started.
+func (a *testApp) importCurds(csv string) response.ImportJobDTO {
+	a.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "curds.csv")
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	_, _ = part.Write([]byte(csv))
+	_ = form.Close()
+	var imported response.ImportJobDTO
+	a.decode(a.do(http.MethodPost, "/api/v1/curd/import", form.FormDataContentType(), body.String()), http.StatusAccepted, &imported)
+	for deadline := time.Now().Add(5 * time.Second); imported.FinishedAt == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			a.t.Fatalf("import not finished: %+v", imported)
+		}
+		a.decode(a.do(http.MethodGet, "/api/v1/curd/import/"+imported.Id, "", nil), http.StatusOK, &imported)
+	}
+	return imported
+}
+
+func TestCurdImportPublishesWebhookEvents(t *testing.T) {
+	app := newWebhookTestApp(t, 1, 10)
+	receiver := newWebhookReceiver(t, http.StatusNoContent)
+	app.subscribe(receiver.URL)
+
+	imported := app.importCurds("name,email\nNam,nam@example.com\nLan,lan@example.com\n")
+	if imported.Status != constant.ImportStatusDone || imported.ImportedRows != 2 {
+		t.Fatalf("import %+v", imported)
+	}
+	receiver.wait(t, 2)
+}
+
+func TestCurdImportScopedByUser(t *testing.T) {
+	app := newWebhookTestApp(t, 1, 10)
+	imported := app.importCurds("name,email\nNam,nam@example.com\nLan,not an email\n")
+	if imported.Status != constant.ImportStatusDone || imported.ImportedRows != 1 || imported.RejectedRows != 1 || !imported.HasReport {
+		t.Fatalf("import %+v", imported)
+	}
+	path := "/api/v1/curd/import/" + imported.Id
+	if w := app.do(http.MethodGet, path+"/report", "", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "not an email") {
+		t.Fatalf("report: %v %v", w.Code, w.Body)
+	}
+
+	// the import is stored with its job, not in the instance that received the file
+	var jobs []model.Job
+	if err := app.Database.DB.Where("type = ?", constant.ImportJobType).Find(&jobs).Error; err != nil || len(jobs) != 1 ||
+		jobs[0].Status != constant.JobStatusDone {
+		t.Fatalf("import jobs %+v: %v", jobs, err)
+	}
+
+	for _, user := range []struct{ userId, companyId interface{} }{{1, 2}, {2, 1}} {
+		app.loginAs(user.userId, user.companyId)
+		for _, p := range []string{path, path + "/report"} {
+			if w := app.do(http.MethodGet, p, "", nil); w.Code != http.StatusNotFound {
+				t.Fatalf("%v by user %v of company %v: %v %v", p, user.userId, user.companyId, w.Code, w.Body)
+			}
+		}
+	}
+}
+
+func TestInterruptedCurdImportFails(t *testing.T) {
+	app := newWebhookTestApp(t, 1, 10)
+	// left running by a crashed instance, its job is given back to the queue
+	interrupted := model.CurdImport{Id: "interrupted", TenantId: "1", CreatedBy: "1", FileName: "curds.csv",
+		FilePath: filepath.Join(t.TempDir(), "curds.csv"), Status: constant.ImportStatusRunning}
+	if err := app.Database.DB.Create(&interrupted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.JobManager.Enqueue(context.Background(), constant.ImportJobType, map[string]string{"import_id": interrupted.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got response.ImportJobDTO
+	for deadline := time.Now().Add(5 * time.Second); got.FinishedAt == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("import not finished: %+v", got)
+		}
+		app.decode(app.do(http.MethodGet, "/api/v1/curd/import/"+interrupted.Id, "", nil), http.StatusOK, &got)
+	}
+	if got.Status != constant.ImportStatusFailed || got.ErrorMessage == "" {
+		t.Fatalf("interrupted import %+v", got)
+	}
+}
+
+func TestCurdSearchApi(t *testing.T) {
+	app := newTestApp(t)
+	curds := make([]model.Curd, constant.SearchMaxCandidates+5)
+	for i := range curds {
+		curds[i] = model.Curd{TenantId: "1", Name: fmt.Sprintf("Nam %v", i), Email: fmt.Sprintf("nam%v@example.com", i)}
+	}
+	curds[0].Email = "nam_0@example.com"
+	if err := app.Database.DB.CreateInBatches(curds, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"", "?q=", "?q=%20-%20"} {
+		w := app.do(http.MethodGet, "/api/v1/curd/search"+query, "", nil)
+		var res response.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusBadRequest || len(res.ErrorFields) != 1 || res.ErrorFields[0].Field != "q" {
+			t.Fatalf("search%v: %v %v", query, w.Code, w.Body)
+		}
+	}
+
+	// the LIKE wildcards are matched literally
+	for query, want := range map[string]int{"/search?q=_": 1, "/search?q=%25": 0, "?name=%25": 0, "?email=_": 1} {
+		var page response.PageDTO
+		app.decode(app.do(http.MethodGet, "/api/v1/curd"+query, "", nil), http.StatusOK, &page)
+		if page.Total != int64(want) {
+			t.Fatalf("%v: total %v, want %v", query, page.Total, want)
+		}
+	}
+
+	// the matches past the ranked candidates are paged too
+	seen := make(map[uint]bool)
+	for p := 1; ; p++ {
+		var items []response.CurdSearchHitDTO
+		page := response.PageDTO{Items: &items}
+		app.decode(app.do(http.MethodGet, fmt.Sprintf("/api/v1/curd/search?q=nam&size=300&page=%v", p), "", nil), http.StatusOK, &page)
+		if page.Total != int64(len(curds)) {
+			t.Fatalf("total %v, want %v", page.Total, len(curds))
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			if seen[item.Id] {
+				t.Fatalf("curd %v found twice", item.Id)
+			}
+			seen[item.Id] = true
+		}
+	}
+	if len(seen) != len(curds) {
+		t.Fatalf("%v curds found, want %v", len(seen), len(curds))
+	}
+}
+
+func TestCurdEmailUniquePerTenant(t *testing.T) {
+	app := newTestApp(t)
+	var created response.CurdDTO
+	app.decode(app.do(http.MethodPost, "/api/v1/curd", "application/json",
+		map[string]string{"name": "Nam", "email": "nam@example.com"}), http.StatusOK, &created)
+
+	// a write racing with the validation is rejected by the unique index
+	duplicate := model.Curd{TenantId: "1", Name: "Nam", Email: "NAM@example.com"}
+	if err := app.Database.DB.Create(&duplicate).Error; !dbutil.IsDuplicateKey(err) {
+		t.Fatalf("duplicate email inserted: %v", err)
+	}
+	other := model.Curd{TenantId: "2", Name: "Nam", Email: "nam@example.com"}
+	if err := app.Database.DB.Create(&other).Error; err != nil {
+		t.Fatalf("email of another tenant: %v", err)
+	}
+
+	// another request takes the email between the validation and the insert
+	var raced bool
+	err := app.Database.DB.Callback().Create().Before("gorm:create").Register("test:race", func(db *gorm.DB) {
+		if curd, ok := db.Statement.Dest.(*model.Curd); ok && curd.Email == "lan@example.com" && !raced {
+			raced = true
+			db.Statement.AddError(db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+				Create(&model.Curd{TenantId: "1", Name: "Lan", Email: "lan@example.com"}).Error)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := app.do(http.MethodPost, "/api/v1/curd", "application/json", map[string]string{"name": "Lan", "email": "lan@example.com"})
+	var res response.Response
+	if err = json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !raced || w.Code != http.StatusBadRequest || len(res.ErrorFields) != 1 || res.ErrorFields[0].Field != "email" {
+		t.Fatalf("create losing the race: %v %v", w.Code, w.Body)
+	}
+
+	// the email of a deleted curd can be reused
+	if w := app.do(http.MethodDelete, "/api/v1/curd/bulk", "application/json", []uint{created.Id}); w.Code != http.StatusOK {
+		t.Fatalf("delete: %v %v", w.Code, w.Body)
+	}
+	app.decode(app.do(http.MethodPost, "/api/v1/curd", "application/json",
+		map[string]string{"name": "Nam", "email": "nam@example.com"}), http.StatusOK, &created)
+}
+
+func TestCurdsScopedByTenant(t *testing.T) {
+	app := newTestApp(t)
+	var created response.CurdDTO
+	app.decode(app.do(http.MethodPost, "/api/v1/curd", "application/json",
+		map[string]string{"name": "Nam", "email": "nam@example.com"}), http.StatusOK, &created)
+	path := fmt.Sprintf("/api/v1/curd/%v", created.Id)
+	// cached for company 1 before company 2 asks
+	app.decode(app.do(http.MethodGet, path, "", nil), http.StatusOK, &response.CurdDTO{})
+	app.decode(app.do(http.MethodGet, "/api/v1/curd", "", nil), http.StatusOK, &response.PageDTO{})
+
+	app.login(2)
+	if w := app.do(http.MethodGet, path, "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("get of another company: %v %v", w.Code, w.Body)
+	}
+	if w := app.do(http.MethodPatch, path, "application/merge-patch+json", `{"name": "Lan"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("patch of another company: %v %v", w.Code, w.Body)
+	}
+	for _, query := range []string{"", "?name=nam", "/search?q=nam"} {
+		var page response.PageDTO
+		app.decode(app.do(http.MethodGet, "/api/v1/curd"+query, "", nil), http.StatusOK, &page)
+		if page.Total != 0 {
+			t.Fatalf("%v of another company: %+v", query, page)
+		}
+	}
+	if w := app.do(http.MethodGet, "/api/v1/curd/export", "", nil); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "nam@example.com") {
+		t.Fatalf("export of another company: %v %v", w.Code, w.Body)
+	}
+	updates := []map[string]interface{}{{"id": created.Id, "name": "Lan", "email": "lan@example.com"}}
+	for method, body := range map[string]interface{}{http.MethodPut: updates, http.MethodDelete: []uint{created.Id}} {
+		var res response.BulkResult
+		app.decode(app.do(method, "/api/v1/curd/bulk", "application/json", body), http.StatusUnprocessableEntity, &res)
+		if res.Items[0].ErrorCode != constant.ErrorCodeNotFound {
+			t.Fatalf("bulk %v of another company: %+v", method, res)
+		}
+	}
+
+	app.login(1)
+	var got response.CurdDTO
+	app.decode(app.do(http.MethodGet, path, "", nil), http.StatusOK, &got)
+	if got.Name != "Nam" {
+		t.Fatalf("curd changed by another company: %+v", got)
+	}
+}
+
+func TestIdempotencyKeepsClientErrors(t *testing.T) {
+	app := newTestApp(t)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/curd", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+app.token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(constant.HeaderIdempotencyKey, "create-nam")
+		w := httptest.NewRecorder()
+		app.Router.Engine.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post(`{"name": "", "email": "nam@example.com"}`)
+	if first.Code != http.StatusBadRequest {
+		t.Fatalf("invalid create: %v %v", first.Code, first.Body)
+	}
+	retry := post(`{"name": "", "email": "nam@example.com"}`)
+	if retry.Code != http.StatusBadRequest || retry.Header().Get(constant.HeaderIdempotentReplayed) != "true" ||
+		retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry of an invalid create: %v %v %v", retry.Code, retry.Header(), retry.Body)
+	}
+	if w := post(`{"name": "Nam", "email": "nam@example.com"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("key reused with a fixed body: %v %v", w.Code, w.Body)
+	}
+}
+
+func TestIdempotencyScopes(t *testing.T) {
+	app := newWebhookTestApp(t, 1, 10)
+	post := func(path string, contentType string, body string, auth bool, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if auth {
+			req.Header.Set("Authorization", "Bearer "+app.token)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set(constant.HeaderIdempotencyKey, "create-nam")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		app.Router.Engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// anonymous callers only get the responses of their own keys
+	body := `{"name": "Nam", "email": "nam@example.com"}`
+	if w := post("/api/public/v1/c", "application/json", body, false, "203.0.113.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("anonymous create: %v %v", w.Code, w.Body)
+	}
+	if w := post("/api/public/v1/c", "application/json", `{"name": "Lan"}`, false, "203.0.113.2:1234"); w.Code != http.StatusOK ||
+		w.Header().Get(constant.HeaderIdempotentReplayed) != "" || !strings.Contains(w.Body.String(), "Lan") {
+		t.Fatalf("create by another anonymous client: %v %v %v", w.Code, w.Header(), w.Body)
+	}
+	if w := post("/api/public/v1/c", "application/json", body, false, "203.0.113.1:1234"); w.Header().Get(constant.HeaderIdempotentReplayed) != "true" {
+		t.Fatalf("retry of the anonymous create: %v %v %v", w.Code, w.Header(), w.Body)
+	}
+
+	// an upload larger than the bodies kept in memory still reaches the handler whole
+	var csv strings.Builder
+	csv.WriteString("name,email,note\n")
+	for i := 0; csv.Len() <= constant.IdempotencyMemoryBodySize; i++ {
+		fmt.Fprintf(&csv, "Nam %v,nam%v@example.com,%v\n", i, i, strings.Repeat("x", 1000))
+	}
+	var upload bytes.Buffer
+	form := multipart.NewWriter(&upload)
+	part, err := form.CreateFormFile("file", "curds.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte(csv.String()))
+	_ = form.Close()
+	var first, retry response.ImportJobDTO
+	w := post("/api/v1/curd/import", form.FormDataContentType(), upload.String(), true, "203.0.113.1:1234")
+	app.decode(w, http.StatusAccepted, &first)
+	w = post("/api/v1/curd/import", form.FormDataContentType(), upload.String(), true, "203.0.113.1:1234")
+	app.decode(w, http.StatusAccepted, &retry)
+	if w.Header().Get(constant.HeaderIdempotentReplayed) != "true" || retry.Id != first.Id {
+		t.Fatalf("retry of the import: %+v, want %+v", retry, first)
+	}
+	for deadline := time.Now().Add(10 * time.Second); first.FinishedAt == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("import not finished: %+v", first)
+		}
+		app.decode(app.do(http.MethodGet, "/api/v1/curd/import/"+first.Id, "", nil), http.StatusOK, &first)
+	}
+	if first.Status != constant.ImportStatusDone || first.ImportedRows != strings.Count(csv.String(), "\n")-1 {
+		t.Fatalf("import %+v", first)
+	}
+}
+
+func TestRateLimitClientIP(t *testing.T) {
+	for _, c := range []struct {
+		name           string
+		trustedProxies string
+		limited        bool
+	}{
+		// anyone could pick its bucket with the header
+		{"untrusted", "", true},
+		// httptest requests come from 192.0.2.1
+		{"trusted", "192.0.2.0/24", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("APP_SERVER_TRUSTEDPROXIES", c.trustedProxies)
+			app := newTestApp(t)
+			limited := false
+			// the public policy allows bursts of 10 requests by ip
+			for i := 0; i < 11; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/api/public/v1/c", strings.NewReader("{}"))
+				req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%v", i))
+				w := httptest.NewRecorder()
+				app.Router.Engine.ServeHTTP(w, req)
+				limited = limited || w.Code == http.StatusTooManyRequests
+			}
+			if limited != c.limited {
+				t.Fatalf("limited %v, want %v", limited, c.limited)
+			}
+		})
+	}
+}
+
+func TestRateLimitRejectionKeepsOtherTokens(t *testing.T) {
+	app := newTestApp(t)
+	// the import policy allows bursts of 10 imports, each also takes a token of the
+	// user policy allowing bursts of 100 requests
+	rejected := 0
+	for i := 0; i < 60; i++ {
+		if app.do(http.MethodPost, "/api/v1/curd/import", "", nil).Code == http.StatusTooManyRequests {
+			rejected++
+		}
+	}
+	if rejected != 50 {
+		t.Fatalf("%v imports rejected, want 50", rejected)
+	}
+	w := app.do(http.MethodGet, "/api/v1/curd", "", nil)
+	if remaining, _ := strconv.Atoi(w.Header().Get(constant.HeaderRateLimitRemaining)); remaining < 80 {
+		t.Fatalf("%v tokens left to the user after the rejected imports", remaining)
+	}
+}
+
+// lagReplica routes the reads to a copy of the database as it is now, like a replica
+// lagging behind every following write.
+func (a *testApp) lagReplica() {
+	a.t.Helper()
+	replica := filepath.Join(a.t.TempDir(), "replica.db")
+	data, err := os.ReadFile(os.Getenv("APP_DATABASE_DBNAME"))
+	if err == nil {
+		err = os.WriteFile(replica, data, 0o600)
+	}
+	if err == nil {
+		err = a.Database.DB.Use(dbresolver.Register(dbresolver.Config{Replicas: []gorm.Dialector{sqlite.Open(replica)}}))
+	}
+	if err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+func TestCachedCurdsLoadFromPrimary(t *testing.T) {
+	app := newTestApp(t)
+	app.lagReplica()
+
+	var created response.CurdDTO
+	app.decode(app.do(http.MethodPost, "/api/v1/curd", "application/json",
+		map[string]string{"name": "Nam", "email": "nam@example.com"}), http.StatusOK, &created)
+	app.decode(app.do(http.MethodGet, fmt.Sprintf("/api/v1/curd/%v", created.Id), "", nil), http.StatusOK, &response.CurdDTO{})
+	var items []response.CurdDTO
+	app.decode(app.do(http.MethodGet, "/api/v1/curd", "", nil), http.StatusOK, &response.PageDTO{Items: &items})
+	if len(items) != 1 {
+		t.Fatalf("listed %+v", items)
+	}
+}
+
+func TestUncachedCurdsReadFromReplica(t *testing.T) {
+	t.Setenv("APP_CACHE_ENABLED", "false")
+	app := newTestApp(t)
+	app.lagReplica()
+
+	var created response.CurdDTO
+	app.decode(app.do(http.MethodPost, "/api/v1/curd", "application/json",
+		map[string]string{"name": "Nam", "email": "nam@example.com"}), http.StatusOK, &created)
+	if w := app.do(http.MethodGet, fmt.Sprintf("/api/v1/curd/%v", created.Id), "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("get from the lagging replica: %v %v", w.Code, w.Body)
+	}
+	var page response.PageDTO
+	app.decode(app.do(http.MethodGet, "/api/v1/curd", "", nil), http.StatusOK, &page)
+	if page.Total != 0 {
+		t.Fatalf("list from the lagging replica: %+v", page)
+	}
+}
+
+func TestTranslationsApplyFromPrimary(t *testing.T) {
+	app := newTestApp(t, constant.RoleAdmin)
+	app.lagReplica()
+	app.decode(app.do(http.MethodPost, "/api/v1/admin/i18n/messages", "application/json",
+		map[string]string{"lang": "vi", "message_id": "error.not_found", "other": "Không có curd này"}),
+		http.StatusOK, &response.TranslationDTO{})
+	if message := app.I18n.Messages("vi")["error.not_found"]; message == nil || message.Other != "Không có curd này" {
+		t.Fatalf("applied message %+v", message)
+	}
+}
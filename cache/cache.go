@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"demo-curd/config"
+	"demo-curd/util/constant"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Cache stores json encoded values by key, the in-memory LRU is local to each
+// instance, a backend shared by the instances, e.g. Redis, can implement it as well.
+type Cache interface {
+	// Get returns false when key is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value for ttl, forever when ttl is 0.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// NewCache returns the cache of cache.store, or a cache keeping nothing when disabled.
+// Add the case of a new backend here.
+func NewCache(c config.Config) (Cache, error) {
+	if !c.Cache.Enabled {
+		return noopCache{}, nil
+	}
+	switch c.Cache.Store {
+	case "", constant.CacheStoreMemory:
+		return NewLRU(c.Cache.Size), nil
+	default:
+		return nil, fmt.Errorf("cache.store: unsupported store %v", c.Cache.Store)
+	}
+}
+
+// GetOrLoad reads key through the cache: on a miss load is called and its result is
+// stored for ttl, unless it's nil so a missing record is loaded again by the next call.
+// Cache failures fall back to load.
+func GetOrLoad[T any](ctx context.Context, c Cache, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	if data, ok, err := c.Get(ctx, key); err == nil && ok {
+		var value T
+		if err = json.Unmarshal(data, &value); err == nil {
+			return value, nil
+		}
+	}
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err == nil && string(data) != "null" {
+		_ = c.Set(ctx, key, data, ttl)
+	}
+	return value, nil
+}
+
+// Version returns the current version of namespace, keys built with it are all
+// invalidated at once by Bump, e.g. the cached pages of a list.
+func Version(ctx context.Context, c Cache, namespace string) (string, error) {
+	data, ok, err := c.Get(ctx, versionKey(namespace))
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return string(data), nil
+	}
+	return Bump(ctx, c, namespace)
+}
+
+// Bump starts a new version of namespace.
+func Bump(ctx context.Context, c Cache, namespace string) (string, error) {
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	return version, c.Set(ctx, versionKey(namespace), []byte(version), 0)
+}
+
+func versionKey(namespace string) string {
+	return "version:" + namespace
+}
+
+type noopCache struct{}
+
+func (noopCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (noopCache) Set(context.Context, string, []byte, time.Duration) error {
+	return nil
+}
+
+func (noopCache) Delete(context.Context, ...string) error {
+	return nil
+}
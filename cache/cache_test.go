@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadSkipsNil(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10)
+	loads := 0
+	load := func(value *string) func() (*string, error) {
+		return func() (*string, error) {
+			loads++
+			return value, nil
+		}
+	}
+
+	if v, err := GetOrLoad(ctx, c, "key", time.Minute, load(nil)); err != nil || v != nil {
+		t.Fatalf("missing value: %v %v", v, err)
+	}
+	found := "found"
+	if v, err := GetOrLoad(ctx, c, "key", time.Minute, load(&found)); err != nil || v == nil || *v != found {
+		t.Fatalf("value created after a miss: %v %v", v, err)
+	}
+	if v, err := GetOrLoad(ctx, c, "key", time.Minute, load(nil)); err != nil || v == nil || *v != found {
+		t.Fatalf("cached value: %v %v", v, err)
+	}
+	if loads != 2 {
+		t.Fatalf("%v loads, want 2", loads)
+	}
+}
@@ -0,0 +1,196 @@
+// Command gen scaffolds the model, dao, service, api, dto and migration layers of a
+// new resource following the conventions of the curd resource, along with an api
+// test, then registers it in wire.go and main.go.
+//
+//	go run ./cmd/gen resource Contact name:string:required email:string age:int
+//
+// Field types: string, int, int64, uint, float64, bool, time. Append ":required" to
+// validate the field as required.
+package main
+
+import (
+	"bytes"
+	"demo-curd/util/constant"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates
+var templates embed.FS
+
+const usage = `Usage:
+  go run ./cmd/gen resource <Name> field:type[:required] ...`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) < 3 || args[0] != "resource" {
+		return errors.New(usage)
+	}
+	res, err := parseResource(args[1], args[2:])
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.ParseFS(templates, "templates/*.tmpl")
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		template string
+		path     string
+	}{
+		{"model.go.tmpl", filepath.Join("model", res.File+".go")},
+		{"dao.go.tmpl", filepath.Join("dao", res.File+"_dao.go")},
+		{"service.go.tmpl", filepath.Join("service", res.File+"_service.go")},
+		{"api.go.tmpl", filepath.Join("api", "v1", res.File+"_api.go")},
+		{"request_dto.go.tmpl", filepath.Join("dto", "request", res.File+"_dto.go")},
+		{"response_dto.go.tmpl", filepath.Join("dto", "response", res.File+"_dto.go")},
+		// run by go test with the app on sqlite, see api_test.go
+		{"api_test.go.tmpl", res.File + "_api_test.go"},
+	}
+	for _, f := range files {
+		if err := render(tmpl, f.template, f.path, res); err != nil {
+			return err
+		}
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	for _, dialect := range []string{constant.DriverMySQL, constant.DriverPostgres, constant.DriverSQLite} {
+		data := sqlData{Resource: res, Dialect: dialect}
+		base := filepath.Join(constant.MigrationPath, dialect, fmt.Sprintf("%v_create_%v", version, res.Table))
+		if err := render(tmpl, "up.sql.tmpl", base+".up.sql", data); err != nil {
+			return err
+		}
+		if err := render(tmpl, "down.sql.tmpl", base+".down.sql", data); err != nil {
+			return err
+		}
+	}
+
+	if err := registerWire(res); err != nil {
+		return err
+	}
+	if err := registerApp(res); err != nil {
+		return err
+	}
+	fmt.Println("Run `swag init && wire` to regenerate the swagger docs and wire_gen.go")
+	return nil
+}
+
+func render(tmpl *template.Template, name string, path string, data interface{}) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%v already exists", path)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	content := buf.Bytes()
+	if strings.HasSuffix(path, ".go") {
+		formatted, err := format.Source(content)
+		if err != nil {
+			return fmt.Errorf("format %v: %w", path, err)
+		}
+		content = formatted
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	fmt.Printf("Created %v\n", path)
+	return os.WriteFile(path, content, 0644)
+}
+
+// registerWire adds the dao, service and api providers of the resource after the
+// matching section comments of InitApp.
+func registerWire(res Resource) error {
+	return patchFile("wire.go", func(src string) (string, error) {
+		var err error
+		insert := func(anchor string, line string) {
+			if err != nil {
+				return
+			}
+			src, err = insertAfter(src, anchor, line)
+		}
+		insert("\t\t// dao\n", fmt.Sprintf("\t\tdao.New%vDao,\n", res.Name))
+		insert("\t\t//service\n", fmt.Sprintf("\t\twire.Struct(new(service.%vService), \"*\"),\n", res.Name))
+		insert("\t\t// api\n", fmt.Sprintf("\t\twire.Struct(new(v1.%vV1Api), \"*\"),\n", res.Name))
+		return src, err
+	})
+}
+
+// registerApp adds the api to the App struct and its route to the authorized group.
+func registerApp(res Resource) error {
+	return patchFile("main.go", func(src string) (string, error) {
+		src, err := insertBeforeBlockEnd(src, "type App struct {", fmt.Sprintf("\t%vV1Api *v1.%vV1Api\n", res.Name, res.Name))
+		if err != nil {
+			return "", err
+		}
+		return insertBeforeBlockEnd(src, `groupV1 := r.Router.Engine.Group("/api/v1")`,
+			fmt.Sprintf("\t\tgroupV1.POST(%q, r.%vV1Api.Create)\n", res.Route, res.Name))
+	})
+}
+
+func patchFile(path string, patch func(src string) (string, error)) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	src, err := patch(string(content))
+	if err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return fmt.Errorf("format %v: %w", path, err)
+	}
+	fmt.Printf("Updated %v\n", path)
+	return os.WriteFile(path, formatted, 0644)
+}
+
+func insertAfter(src string, anchor string, text string) (string, error) {
+	i := strings.Index(src, anchor)
+	if i < 0 {
+		return "", fmt.Errorf("anchor %q not found", strings.TrimSpace(anchor))
+	}
+	i += len(anchor)
+	return src[:i] + text + src[i:], nil
+}
+
+// insertBeforeBlockEnd inserts text before the closing brace of the first block
+// opened on or after the anchor line.
+func insertBeforeBlockEnd(src string, anchor string, text string) (string, error) {
+	start := strings.Index(src, anchor)
+	if start < 0 {
+		return "", fmt.Errorf("anchor %q not found", anchor)
+	}
+	open := strings.Index(src[start:], "{")
+	if open < 0 {
+		return "", fmt.Errorf("no block after %q", anchor)
+	}
+	depth := 0
+	for i := start + open; i < len(src); i++ {
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				lineStart := strings.LastIndex(src[:i], "\n") + 1
+				return src[:lineStart] + text + src[lineStart:], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unterminated block after %q", anchor)
+}
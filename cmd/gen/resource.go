@@ -0,0 +1,178 @@
+package main
+
+import (
+	"demo-curd/util/constant"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var identPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+type Resource struct {
+	Name    string // Go type name, e.g. ContactGroup
+	Var     string // local variable name, e.g. contactGroup
+	File    string // file name prefix, e.g. contact_group
+	Table   string // table name, e.g. contact_group
+	Route   string // route path under /api/v1, e.g. contact-group
+	Label   string // swagger summary label, e.g. contact group
+	Tag     string // swagger tag, e.g. CONTACT GROUP
+	Fields  []Field
+	HasTime bool
+}
+
+type Field struct {
+	Name     string // Go field name, e.g. FirstName
+	Column   string // column name, e.g. first_name
+	Json     string // json name, e.g. first_name
+	Type     string // type given on the command line, e.g. string
+	GoType   string
+	Required bool
+}
+
+type sqlData struct {
+	Resource
+	Dialect string
+}
+
+// go type, mysql, postgres, sqlite
+var fieldTypes = map[string][4]string{
+	"string":  {"string", "VARCHAR(255)", "VARCHAR(255)", "TEXT"},
+	"int":     {"int", "INT", "INTEGER", "INTEGER"},
+	"int64":   {"int64", "BIGINT", "BIGINT", "INTEGER"},
+	"uint":    {"uint", "INT UNSIGNED", "BIGINT", "INTEGER"},
+	"float64": {"float64", "DOUBLE", "DOUBLE PRECISION", "REAL"},
+	"bool":    {"bool", "TINYINT(1)", "BOOLEAN", "NUMERIC"},
+	"time":    {"time.Time", "DATETIME(3) NULL", "TIMESTAMPTZ NULL", "DATETIME NULL"},
+}
+
+// reserved by the Id and gorm.Model fields every model has
+var reservedColumns = []string{"id", "created_at", "updated_at", "deleted_at"}
+
+func parseResource(name string, specs []string) (Resource, error) {
+	if !identPattern.MatchString(name) {
+		return Resource{}, fmt.Errorf("invalid resource name: %v", name)
+	}
+	words := splitWords(name)
+	res := Resource{
+		Name:  pascal(words),
+		File:  strings.Join(words, "_"),
+		Table: strings.Join(words, "_"),
+		Route: strings.Join(words, "-"),
+		Label: strings.Join(words, " "),
+		Tag:   strings.ToUpper(strings.Join(words, " ")),
+	}
+	res.Var = strings.ToLower(res.Name[:1]) + res.Name[1:]
+
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		if len(parts) < 2 || len(parts) > 3 || !identPattern.MatchString(parts[0]) {
+			return Resource{}, fmt.Errorf("invalid field %q, expected name:type[:required]", spec)
+		}
+		types, ok := fieldTypes[parts[1]]
+		if !ok {
+			return Resource{}, fmt.Errorf("invalid type of field %v: %v", parts[0], parts[1])
+		}
+		if len(parts) == 3 && parts[2] != "required" {
+			return Resource{}, fmt.Errorf("invalid option of field %v: %v", parts[0], parts[2])
+		}
+		fieldWords := splitWords(parts[0])
+		f := Field{
+			Name:     pascal(fieldWords),
+			Column:   strings.Join(fieldWords, "_"),
+			Json:     strings.Join(fieldWords, "_"),
+			Type:     parts[1],
+			GoType:   types[0],
+			Required: len(parts) == 3,
+		}
+		for _, c := range reservedColumns {
+			if f.Column == c {
+				return Resource{}, fmt.Errorf("field %v is generated for every resource", f.Column)
+			}
+		}
+		if seen[f.Column] {
+			return Resource{}, fmt.Errorf("duplicate field: %v", f.Column)
+		}
+		seen[f.Column] = true
+		res.HasTime = res.HasTime || f.Type == "time"
+		res.Fields = append(res.Fields, f)
+	}
+	return res, nil
+}
+
+// HasRequired reports whether a field is required.
+func (r Resource) HasRequired() bool {
+	for _, f := range r.Fields {
+		if f.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// Sample returns a valid Go value of the field, for the generated test.
+func (f Field) Sample() string {
+	switch f.Type {
+	case "string":
+		return strconv.Quote(f.Column)
+	case "bool":
+		return "true"
+	case "time":
+		return "time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)"
+	default:
+		return "1"
+	}
+}
+
+func (f Field) SqlType(dialect string) string {
+	types := fieldTypes[f.Type]
+	switch dialect {
+	case constant.DriverMySQL:
+		return types[1]
+	case constant.DriverPostgres:
+		return types[2]
+	default:
+		return types[3]
+	}
+}
+
+// splitWords splits camelCase, PascalCase and snake_case names into lower case words.
+func splitWords(name string) []string {
+	var words []string
+	var current []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' {
+			if len(current) > 0 {
+				words = append(words, string(current))
+				current = nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				words = append(words, string(current))
+				current = nil
+			}
+		}
+		current = append(current, unicode.ToLower(r))
+	}
+	if len(current) > 0 {
+		words = append(words, string(current))
+	}
+	return words
+}
+
+// pascal joins lower case words into an exported Go name.
+func pascal(words []string) string {
+	var b strings.Builder
+	for _, w := range words {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
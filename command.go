@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"demo-curd/config"
+	"demo-curd/i18n"
+	"demo-curd/migration"
+	"demo-curd/util/constant"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const usage = `Usage:
+  demo-curd                         run the API server
+  demo-curd migrate up              apply all pending migrations
+  demo-curd migrate down [n]        roll back the last n migrations (default 1)
+  demo-curd migrate status          show applied and pending migrations
+  demo-curd migrate force <version> mark a migration that failed midway as applied
+  demo-curd migrate create <name>   create a new up/down migration pair per dialect
+  demo-curd search reindex          recompute the search text of every curd
+  demo-curd config validate [env]   check the config of env (default $ENVIRONMENT)
+  demo-curd config print [env]      print the merged config of env, secrets masked
+  demo-curd i18n missing [env]      list the messages missing in a language of env`
+
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	case "search":
+		return runSearch(args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "i18n":
+		return runI18n(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q\n%v", args[0], usage)
+	}
+}
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	// create only writes files, no database connection needed
+	if args[0] == "create" {
+		if len(args) < 2 {
+			return errors.New("migration name is required")
+		}
+		paths, err := migration.Create(constant.MigrationPath, args[1])
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			fmt.Printf("Created %v\n", path)
+		}
+		return nil
+	}
+
+	migrator, err := InitMigrator()
+	if err != nil {
+		return err
+	}
+	defer migrator.Db.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations: %v", args[1])
+			}
+		}
+		return migrator.Down(ctx, n)
+	case "force":
+		if len(args) < 2 {
+			return errors.New("migration version is required")
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid migration version: %v", args[1])
+		}
+		return migrator.Force(ctx, version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			status, appliedAt := "pending", ""
+			if s.Applied {
+				status = "applied"
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Dirty {
+				status = "dirty"
+			}
+			if s.Failed {
+				status = "failed"
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", s.Version, s.Name, status, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%v", args[0], usage)
+	}
+}
+
+func runSearch(args []string) error {
+	if len(args) == 0 || args[0] != "reindex" {
+		return errors.New(usage)
+	}
+	curdDao, err := InitCurdDao()
+	if err != nil {
+		return err
+	}
+	defer curdDao.Db.Close()
+	updated, err := curdDao.Reindex(context.Background(), constant.BulkBatchSize)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reindexed %v curds\n", updated)
+	return nil
+}
+
+// runConfig exits with 1 when the config is invalid, so CI can check the config of
+// every environment, e.g. demo-curd config validate prod.
+func runConfig(args []string) error {
+	if len(args) == 0 || (args[0] != "validate" && args[0] != "print") {
+		return errors.New(usage)
+	}
+	if len(args) > 1 {
+		if err := os.Setenv(constant.EnvKey, args[1]); err != nil {
+			return err
+		}
+	}
+	c, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if args[0] == "validate" {
+		fmt.Println("Config is valid")
+		return nil
+	}
+	// the secrets are masked by Config.MarshalJSON
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// runI18n exits with 1 when a message is missing in a language, so CI can check the
+// message files, e.g. demo-curd i18n missing prod. The translations of the database are
+// left out.
+func runI18n(args []string) error {
+	if len(args) == 0 || args[0] != "missing" {
+		return errors.New(usage)
+	}
+	if len(args) > 1 {
+		if err := os.Setenv(constant.EnvKey, args[1]); err != nil {
+			return err
+		}
+	}
+	c, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	catalogs, err := i18n.NewI18n(c, nil)
+	if err != nil {
+		return err
+	}
+	missing := catalogs.Missing()
+	if len(missing) == 0 {
+		fmt.Println("No missing message")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LANG\tMESSAGE")
+	for _, lang := range c.I18n.Langs {
+		for _, id := range missing[lang] {
+			fmt.Fprintf(w, "%v\t%v\n", lang, id)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	os.Exit(1)
+	return nil
+}
@@ -2,28 +2,37 @@ package config
 
 import (
 	"demo-curd/util/constant"
+	"errors"
 	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+)
+
+var (
+	decodeErrorRegexp = regexp.MustCompile(`^error decoding '([^']+)': (.*)$`)
+	fieldRegexp       = regexp.MustCompile(`'[A-Z][\w.\[\]]*'`)
 )
 
 type Config struct {
 	Env      string
 	Database struct {
-		Host            string `yaml:"host"`
-		Port            string `yaml:"port"`
-		Username        string `yaml:"username"`
-		Password        string `yaml:"password"`
-		Dbname          string `yaml:"dbname"`
-		MaxIdleConns    int    `yaml:"maxIdleConns"`
-		MaxOpenConns    int    `yaml:"maxOpenConns"`
-		ConnMaxLifetime string `yaml:"connMaxLifetime"`
+		DatabaseConfig `yaml:",inline" mapstructure:",squash"`
+		MigrateOnStart bool                      `yaml:"migrateOnStart"`
+		AutoMigrate    bool                      `yaml:"autoMigrate"`
+		Replicas       []DatabaseConfig          `yaml:"replicas"`
+		Datasources    map[string]DatabaseConfig `yaml:"datasources"`
 	} `yaml:"database"`
 
 	Server struct {
 		Port string `yaml:"port"`
+		// IPs or CIDRs of the proxies whose X-Forwarded-For is trusted for the client IP
+		TrustedProxies []string `yaml:"trustedProxies"`
 	} `yaml:"server"`
 
 	RabbitMQ struct {
@@ -44,25 +53,29 @@ type Config struct {
 	} `yaml:"rabbitmq"`
 
 	Jwt struct {
-		Realm              string `yaml:"realm"`
-		SigningAlg         string `yaml:"signAlg"`
-		Secret             string `yaml:"secret"`
-		ExpiredTime        string `yaml:"expiredTime"`
-		RefreshExpTime     string `yaml:"refreshExpTime"`
-		LongRefreshExpTime string `yaml:"longRefreshExpTime"`
+		Realm              string        `yaml:"realm"`
+		SigningAlg         string        `yaml:"signAlg" mapstructure:"signAlg"`
+		Secret             string        `yaml:"secret"`
+		ExpiredTime        time.Duration `yaml:"expiredTime"`
+		RefreshExpTime     time.Duration `yaml:"refreshExpTime"`
+		LongRefreshExpTime time.Duration `yaml:"longRefreshExpTime"`
 	} `yaml:"jwt"`
 
 	I18n struct {
 		Langs []string `yaml:"langs"`
+		// files of this directory override the embedded messages, e.g. a mounted volume
+		Dir string `yaml:"dir"`
+		// period of the refresh of the messages edited by the admins
+		RefreshInterval time.Duration `yaml:"refreshInterval"`
 	} `yaml:"i18n"`
 
 	CORS struct {
-		AllowOrigins     []string `yaml:"allowOrigins"`
-		AllowMethods     []string `yaml:"allowMethods"`
-		AllowHeaders     []string `yaml:"allowHeaders"`
-		ExposeHeaders    []string `yaml:"exposeHeaders"`
-		AllowCredentials bool     `yaml:"allowCredentials"`
-		MaxAge           string   `yaml:"maxAge"`
+		AllowOrigins     []string      `yaml:"allowOrigins"`
+		AllowMethods     []string      `yaml:"allowMethods"`
+		AllowHeaders     []string      `yaml:"allowHeaders"`
+		ExposeHeaders    []string      `yaml:"exposeHeaders"`
+		AllowCredentials bool          `yaml:"allowCredentials"`
+		MaxAge           time.Duration `yaml:"maxAge"`
 	} `yaml:"cors"`
 
 	HostUrl map[string]string `yaml:"hostUrl"`
@@ -71,15 +84,152 @@ type Config struct {
 		AuthorizedRequests []ConfigAuthorizedRequests `yaml:"authorizedRequests"`
 	} `yaml:"security"`
 
+	Job struct {
+		Workers      int                    `yaml:"workers"`
+		PollInterval time.Duration          `yaml:"pollInterval"`
+		MaxAttempts  int                    `yaml:"maxAttempts"`
+		BackoffBase  time.Duration          `yaml:"backoffBase"`
+		BackoffMax   time.Duration          `yaml:"backoffMax"`
+		LeaseTimeout time.Duration          `yaml:"leaseTimeout"`
+		Retention    time.Duration          `yaml:"retention"`
+		Concurrency  map[string]int         `yaml:"concurrency"`
+		Schedules    map[string]JobSchedule `yaml:"schedules"`
+	} `yaml:"job"`
+
+	Webhook struct {
+		Timeout      time.Duration `yaml:"timeout"`
+		MaxAttempts  int           `yaml:"maxAttempts"`
+		DisableAfter int           `yaml:"disableAfter"`
+		// deliver to loopback, private and link-local addresses, for local receivers
+		AllowPrivateNetworks bool `yaml:"allowPrivateNetworks"`
+	} `yaml:"webhook"`
+
+	Cache struct {
+		Enabled bool `yaml:"enabled"`
+		// memory, an LRU local to each instance
+		Store string `yaml:"store"`
+		// max entries of the memory store
+		Size int           `yaml:"size"`
+		Ttl  time.Duration `yaml:"ttl"`
+		// the entries are loaded from the primary for this long after an invalidation
+		PrimaryWindow time.Duration `yaml:"primaryWindow"`
+	} `yaml:"cache"`
+
+	RateLimit struct {
+		Enabled bool `yaml:"enabled"`
+		// memory, the buckets of each instance are kept apart
+		Store    string            `yaml:"store"`
+		Policies []RateLimitPolicy `yaml:"policies"`
+	} `yaml:"rateLimit"`
+
+	Idempotency struct {
+		Ttl time.Duration `yaml:"ttl"`
+	} `yaml:"idempotency"`
+
+	Import struct {
+		// uploaded files and error reports, shared by the instances since any of them
+		// runs the import and serves the report
+		Dir string `yaml:"dir"`
+	} `yaml:"import"`
+
+	Validation struct {
+		// region of the phone numbers written without country code, e.g. VN
+		PhoneRegion string `yaml:"phoneRegion"`
+		// accepted cities, any city is accepted when empty
+		Cities []string `yaml:"cities"`
+	} `yaml:"validation"`
+
 	Log struct {
 		Level string `yaml:"level"`
+		// json, or console for humans
+		Format string `yaml:"format"`
+		// sql queries slower than this are logged as warnings
+		SlowQueryThreshold time.Duration `yaml:"slowQueryThreshold"`
 	} `yaml:"log"`
 
+	Tracing struct {
+		Enabled bool `yaml:"enabled"`
+		// otlp, to a collector over gRPC, or stdout
+		Exporter string `yaml:"exporter"`
+		// host:port of the otlp collector
+		Endpoint string `yaml:"endpoint"`
+		// no TLS to the collector
+		Insecure    bool   `yaml:"insecure"`
+		ServiceName string `yaml:"serviceName"`
+		// share of the traces recorded, from 0 to 1, children follow their parent
+		SampleRatio float64 `yaml:"sampleRatio"`
+	} `yaml:"tracing"`
+
 	Swagger struct {
 		Url string `yaml:"url"`
 	} `yaml:"swagger"`
 }
 
+type DatabaseConfig struct {
+	Driver          string        `yaml:"driver"`
+	Host            string        `yaml:"host"`
+	Port            string        `yaml:"port"`
+	Username        string        `yaml:"username"`
+	Password        string        `yaml:"password"`
+	Dbname          string        `yaml:"dbname"`
+	SslMode         string        `yaml:"sslMode"`
+	Charset         string        `yaml:"charset"`
+	Timezone        string        `yaml:"timezone"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+}
+
+// Inherit returns a copy of c where empty fields are taken from parent, so a replica
+// only needs to declare what differs from its primary, usually the host.
+func (c DatabaseConfig) Inherit(parent DatabaseConfig) DatabaseConfig {
+	inherit := func(v *string, p string) {
+		if *v == "" {
+			*v = p
+		}
+	}
+	inherit(&c.Driver, parent.Driver)
+	inherit(&c.Host, parent.Host)
+	inherit(&c.Port, parent.Port)
+	inherit(&c.Username, parent.Username)
+	inherit(&c.Password, parent.Password)
+	inherit(&c.Dbname, parent.Dbname)
+	inherit(&c.SslMode, parent.SslMode)
+	inherit(&c.Charset, parent.Charset)
+	inherit(&c.Timezone, parent.Timezone)
+	if c.ConnMaxLifetime == 0 {
+		c.ConnMaxLifetime = parent.ConnMaxLifetime
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = parent.MaxIdleConns
+	}
+	if c.MaxOpenConns == 0 {
+		c.MaxOpenConns = parent.MaxOpenConns
+	}
+	return c
+}
+
+// JobSchedule enqueues a job of Type with the json Payload on every Cron tick, e.g.
+// "@daily" or "*/5 * * * *".
+type JobSchedule struct {
+	Cron    string `yaml:"cron"`
+	Type    string `yaml:"type"`
+	Payload string `yaml:"payload"`
+}
+
+// RateLimitPolicy allows Rate requests per Period with bursts of up to Burst requests
+// (Rate by default) to the routes matching Urls, the patterns of
+// ConfigAuthorizedRequests. Key is the bucket a request counts against: ip, user (the
+// JWT user_id, the ip when anonymous) or route (shared by all the clients).
+type RateLimitPolicy struct {
+	Name   string        `yaml:"name"`
+	Urls   []string      `yaml:"urls"`
+	Key    string        `yaml:"key"`
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+}
+
 type ConfigAuthorizedRequests struct {
 	Urls        []string                `yaml:"urls"`
 	Access      constant.SecurityAccess `yaml:"access"`
@@ -87,43 +237,132 @@ type ConfigAuthorizedRequests struct {
 	Permissions []string                `yaml:"permissions"`
 }
 
-// LoadConfig reads configuration from file or environment variables.
-func LoadConfig() (c Config, err error) {
+// LoadConfig reads the configuration of the environment, applies the defaults and
+// validates it. All the problems are reported at once in a *ValidationError. Each
+// layer overrides the previous ones:
+//   - config/app.yml, shared by the environments, optional
+//   - config/app-<env>.yml
+//   - config/app.local.yml, the overrides of a developer machine, not committed
+//   - the APP_ environment variables, see bindEnv
+func LoadConfig() (Config, error) {
 	env := extractEnv()
-	defer func() {
-		if err != nil {
-			c = Config{
-				Env: env,
-			}
+	var c Config
+	dir, err := Dir()
+	if err != nil {
+		return c, err
+	}
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	files, err := mergeLayers(v, dir, env)
+	if err != nil {
+		return c, err
+	}
+	if err = bindEnv(v); err != nil {
+		return c, err
+	}
+	// decoding goes on after an invalid field, so its errors are reported along with
+	// the validation ones
+	var problems []string
+	if err = v.Unmarshal(&c); err != nil {
+		var decodeErr *mapstructure.Error
+		if !errors.As(err, &decodeErr) {
+			return c, err
+		}
+		for _, e := range decodeErr.Errors {
+			problems = append(problems, decodeProblem(e))
 		}
-	}()
-	// get current path
+	}
+	c.Env = env
+	c.SetDefaults()
+	if err = c.Validate(); err != nil {
+		problems = append(problems, err.(*ValidationError).Problems...)
+	}
+	if len(problems) > 0 {
+		return c, &ValidationError{File: strings.Join(files, ", "), Problems: problems}
+	}
+	log.Info().Strs("files", files).Msgf("Config of environment %v loaded", env)
+	return c, nil
+}
+
+// Dir returns the absolute path of the config directory.
+func Dir() (string, error) {
 	pwd, err := os.Getwd()
 	if err != nil {
-		return
+		return "", err
 	}
-	path, err := getAbsPath(pwd)
+	path, err := filepath.Abs(pwd)
 	if err != nil {
-		return
+		return "", err
+	}
+	return filepath.Join(path, constant.ConfigPath), nil
+}
+
+// mergeLayers merges the config files of env found in dir and returns their paths.
+func mergeLayers(v *viper.Viper, dir string, env string) ([]string, error) {
+	layers := []struct {
+		name     string
+		required bool
+	}{
+		{"app", false},
+		{"app-" + strings.ToLower(env), true},
+		{"app.local", false},
+	}
+	var files []string
+	for _, layer := range layers {
+		file := findConfigFile(dir, layer.name)
+		if file == "" {
+			if layer.required {
+				return nil, fmt.Errorf("config of environment %v not found: no %v.yml in %v", env, layer.name, dir)
+			}
+			continue
+		}
+		v.SetConfigFile(file)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("read config %v: %w", file, err)
+		}
+		files = append(files, file)
 	}
+	return files, nil
+}
 
-	// load config from config directory
-	if path == "/" {
-		viper.AddConfigPath("/config")
-	} else {
-		viper.AddConfigPath(fmt.Sprintf("%v/config", path))
+// findConfigFile returns the path of name.yml or name.yaml in dir, "" if none exists.
+func findConfigFile(dir string, name string) string {
+	for _, ext := range []string{".yml", ".yaml"} {
+		file := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
 	}
-	viper.SetConfigName(fmt.Sprintf("app-%v", strings.ToLower(env)))
-	viper.SetConfigType("yaml")
-	viper.AutomaticEnv()
+	return ""
+}
 
-	if err = viper.ReadInConfig(); err != nil {
-		return
+// decodeProblem names the fields of a decoding error with the keys of the config
+// file, e.g. "error decoding 'CORS.MaxAge': reason" becomes "cors.maxAge: reason".
+func decodeProblem(e string) string {
+	if match := decodeErrorRegexp.FindStringSubmatch(e); match != nil {
+		return configKey(match[1]) + ": " + match[2]
 	}
-	if err = viper.Unmarshal(&c); err != nil {
-		return
+	return fieldRegexp.ReplaceAllStringFunc(e, func(field string) string {
+		return "'" + configKey(strings.Trim(field, "'")) + "'"
+	})
+}
+
+func configKey(field string) string {
+	segments := strings.Split(field, ".")
+	for i, segment := range segments {
+		if segment == strings.ToUpper(segment) {
+			segments[i] = strings.ToLower(segment)
+		} else {
+			segments[i] = strings.ToLower(segment[:1]) + segment[1:]
+		}
 	}
-	return c, nil
+	return strings.Join(segments, ".")
+}
+
+// IsDev reports whether the application runs in a development environment.
+func (c Config) IsDev() bool {
+	return strings.EqualFold(c.Env, constant.EnvDev) || strings.EqualFold(c.Env, constant.EnvLocal)
 }
 
 func extractEnv() string {
@@ -136,11 +375,3 @@ func extractEnv() string {
 	}
 	return env
 }
-
-func getAbsPath(dir string) (string, error) {
-	path, err := filepath.Abs(dir)
-	if err != nil {
-		panic(err)
-	}
-	return path, nil
-}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const envPrefix = "APP"
+
+// bindEnv lets the environment variables override every key of the config: the key
+// upper cased with "_" between its parts and prefixed by APP_, e.g. APP_DATABASE_PASSWORD
+// for database.password or APP_I18N_LANGS=vi,en for a list. APP_<KEY>_FILE reads the
+// value from a file instead, e.g. a mounted secret. Maps and lists of objects, e.g.
+// database.datasources, can only be set in the files.
+func bindEnv(v *viper.Viper) error {
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		if err := v.BindEnv(key); err != nil {
+			return err
+		}
+		if err := readSecretFile(v, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable overriding key, e.g. APP_JWT_SECRET for
+// jwt.secret.
+func EnvName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func readSecretFile(v *viper.Viper, key string) error {
+	name := EnvName(key) + "_FILE"
+	path, ok := os.LookupEnv(name)
+	if !ok || path == "" {
+		return nil
+	}
+	if _, ok := os.LookupEnv(EnvName(key)); ok {
+		return fmt.Errorf("%v and %v are both set, keep one", EnvName(key), name)
+	}
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+	// editors and echo usually end the file with a new line
+	v.Set(key, strings.TrimRight(string(value), "\r\n"))
+	return nil
+}
+
+// configKeys lists the keys of the values of t that can be set from a single string,
+// following the yaml tags.
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if strings.Contains(field.Tag.Get("mapstructure"), "squash") {
+			keys = append(keys, configKeys(field.Type, prefix)...)
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		key := prefix + strings.ToLower(name)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		switch {
+		case fieldType == reflect.TypeOf(time.Duration(0)):
+			keys = append(keys, key)
+		case fieldType.Kind() == reflect.Struct:
+			keys = append(keys, configKeys(fieldType, key+".")...)
+		case fieldType.Kind() == reflect.Map:
+		case fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,48 @@
+package config
+
+import "encoding/json"
+
+const redactedValue = "******"
+
+// Redacted returns a copy of c with the secrets masked, c is left as is.
+func (c Config) Redacted() Config {
+	c.Database.Password = redact(c.Database.Password)
+	replicas := make([]DatabaseConfig, len(c.Database.Replicas))
+	for i, replica := range c.Database.Replicas {
+		replica.Password = redact(replica.Password)
+		replicas[i] = replica
+	}
+	c.Database.Replicas = replicas
+	datasources := make(map[string]DatabaseConfig, len(c.Database.Datasources))
+	for name, dc := range c.Database.Datasources {
+		dc.Password = redact(dc.Password)
+		datasources[name] = dc
+	}
+	c.Database.Datasources = datasources
+	c.RabbitMQ.Password = redact(c.RabbitMQ.Password)
+	c.Jwt.Secret = redact(c.Jwt.Secret)
+	return c
+}
+
+// MarshalJSON masks the secrets, so logging the config doesn't leak them.
+func (c Config) MarshalJSON() ([]byte, error) {
+	// the alias drops the methods of Config, or json.Marshal would call this again
+	type config Config
+	return json.Marshal(config(c.Redacted()))
+}
+
+// String masks the secrets like MarshalJSON, for the %v and %s verbs.
+func (c Config) String() string {
+	data, err := c.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"demo-curd/util/constant"
+	"fmt"
+	"github.com/nyaruka/phonenumbers"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
+	"golang.org/x/text/language"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ValidationError lists every invalid field of a config, one "field: problem" per line.
+type ValidationError struct {
+	// config file the problems were found in, if any
+	File     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid config")
+	if e.File != "" {
+		b.WriteString(" " + e.File)
+	}
+	fmt.Fprintf(&b, ", %v problem(s):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - " + p)
+	}
+	return b.String()
+}
+
+// SetDefaults fills the unset fields with their default values, so the consumers can
+// read the config as is.
+func (c *Config) SetDefaults() {
+	setDefault(&c.Server.Port, constant.DefaultServerPort)
+	c.Database.DatabaseConfig.setDefaults()
+	for name, dc := range c.Database.Datasources {
+		dc.setDefaults()
+		c.Database.Datasources[name] = dc
+	}
+
+	setDefault(&c.Jwt.SigningAlg, constant.JwtDefaultSigningAlg)
+	setDefault(&c.Jwt.ExpiredTime, constant.JwtDefaultExpiredTime)
+	setDefault(&c.Jwt.RefreshExpTime, constant.JwtDefaultRefreshExpTime)
+	setDefault(&c.Jwt.LongRefreshExpTime, c.Jwt.RefreshExpTime)
+
+	c.I18n.Langs = normalizeLangs(c.I18n.Langs)
+	if len(c.I18n.Langs) == 0 {
+		c.I18n.Langs = []string{constant.DefaultLang}
+	}
+	setDefault(&c.I18n.RefreshInterval, constant.I18nDefaultRefreshInterval)
+	setDefault(&c.CORS.MaxAge, constant.DefaultCorsMaxAge)
+
+	setDefault(&c.Job.Workers, constant.JobDefaultWorkers)
+	setDefault(&c.Job.MaxAttempts, constant.JobDefaultMaxAttempts)
+	setDefault(&c.Job.PollInterval, constant.JobDefaultPollInterval)
+	setDefault(&c.Job.BackoffBase, constant.JobDefaultBackoffBase)
+	setDefault(&c.Job.BackoffMax, constant.JobDefaultBackoffMax)
+	setDefault(&c.Job.LeaseTimeout, constant.JobDefaultLeaseTimeout)
+	setDefault(&c.Job.Retention, constant.JobDefaultRetention)
+
+	setDefault(&c.Webhook.Timeout, constant.WebhookDefaultTimeout)
+	setDefault(&c.Webhook.MaxAttempts, constant.WebhookDefaultMaxAttempts)
+	setDefault(&c.Webhook.DisableAfter, constant.WebhookDefaultDisableAfter)
+
+	setDefault(&c.Cache.Store, constant.CacheStoreMemory)
+	setDefault(&c.Cache.Size, constant.CacheDefaultSize)
+	setDefault(&c.Cache.Ttl, constant.CacheDefaultTTL)
+	setDefault(&c.Cache.PrimaryWindow, constant.CacheDefaultPrimaryWindow)
+
+	setDefault(&c.RateLimit.Store, constant.RateLimitStoreMemory)
+	for i := range c.RateLimit.Policies {
+		p := &c.RateLimit.Policies[i]
+		setDefault(&p.Name, fmt.Sprint(p.Urls))
+		setDefault(&p.Period, constant.RateLimitDefaultPeriod)
+		setDefault(&p.Burst, p.Rate)
+	}
+
+	setDefault(&c.Idempotency.Ttl, constant.IdempotencyDefaultTTL)
+	setDefault(&c.Import.Dir, filepath.Join(os.TempDir(), constant.ImportDefaultDirName))
+	c.Validation.PhoneRegion = strings.ToUpper(c.Validation.PhoneRegion)
+	setDefault(&c.Validation.PhoneRegion, constant.DefaultPhoneRegion)
+	setDefault(&c.Log.Level, constant.DefaultLogLevel)
+	setDefault(&c.Log.Format, constant.LogFormatJSON)
+	setDefault(&c.Log.SlowQueryThreshold, constant.DefaultSlowQueryThreshold)
+
+	setDefault(&c.Tracing.Exporter, constant.TracingExporterOTLP)
+	setDefault(&c.Tracing.ServiceName, constant.DefaultServiceName)
+	setDefault(&c.Tracing.SampleRatio, 1)
+}
+
+func (c *DatabaseConfig) setDefaults() {
+	setDefault(&c.ConnMaxLifetime, constant.DefaultConnMaxLifetime)
+}
+
+func setDefault[T comparable](v *T, def T) {
+	var zero T
+	if *v == zero {
+		*v = def
+	}
+}
+
+// normalizeLangs splits the languages given as "vi, en" in a single item, e.g. from an
+// environment variable, and returns them canonicalized, e.g. en-US for en_us, without
+// duplicates. Invalid tags are kept as is for Validate.
+func normalizeLangs(langs []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, item := range langs {
+		for _, lang := range strings.FieldsFunc(item, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			if tag, err := language.Parse(lang); err == nil {
+				lang = tag.String()
+			}
+			if !seen[lang] {
+				seen[lang] = true
+				normalized = append(normalized, lang)
+			}
+		}
+	}
+	return normalized
+}
+
+// Validate checks the config once the defaults are set, it returns a *ValidationError
+// listing all the invalid fields.
+func (c Config) Validate() error {
+	v := &validator{}
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		v.addf("server.port", "must be a port number, got %q", c.Server.Port)
+	}
+	for i, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			v.addf(fmt.Sprintf("server.trustedProxies[%v]", i), "must be an IP or a CIDR, got %q", proxy)
+		}
+	}
+
+	c.Database.DatabaseConfig.validate(v, "database")
+	for i, replica := range c.Database.Replicas {
+		replica.Inherit(c.Database.DatabaseConfig).validate(v, fmt.Sprintf("database.replicas[%v]", i))
+	}
+	for _, name := range sortedKeys(c.Database.Datasources) {
+		c.Database.Datasources[name].validate(v, "database.datasources."+name)
+	}
+
+	v.required("jwt.realm", c.Jwt.Realm)
+	v.required("jwt.secret", c.Jwt.Secret)
+	v.oneOf("jwt.signAlg", c.Jwt.SigningAlg, "HS256", "HS384", "HS512")
+	v.positive("jwt.expiredTime", c.Jwt.ExpiredTime)
+	v.positive("jwt.refreshExpTime", c.Jwt.RefreshExpTime)
+	v.positive("jwt.longRefreshExpTime", c.Jwt.LongRefreshExpTime)
+
+	hasDefaultLang := false
+	for _, lang := range c.I18n.Langs {
+		hasDefaultLang = hasDefaultLang || lang == constant.DefaultLang
+		if _, err := language.Parse(lang); err != nil {
+			v.addf("i18n.langs", "%v is not a language tag", lang)
+		}
+	}
+	if !hasDefaultLang {
+		v.addf("i18n.langs", "must include the default language %v", constant.DefaultLang)
+	}
+	v.positive("i18n.refreshInterval", c.I18n.RefreshInterval)
+	if c.I18n.Dir != "" {
+		if info, err := os.Stat(c.I18n.Dir); err != nil || !info.IsDir() {
+			v.addf("i18n.dir", "%v is not a directory", c.I18n.Dir)
+		}
+	}
+
+	if len(c.CORS.AllowOrigins) == 0 {
+		v.addf("cors.allowOrigins", "is required, use * to allow all the origins")
+	}
+	for i, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.addf(fmt.Sprintf("cors.allowOrigins[%v]", i), "must be * or an http(s) origin, got %q", origin)
+		}
+	}
+	v.positive("cors.maxAge", c.CORS.MaxAge)
+
+	for i, req := range c.Security.AuthorizedRequests {
+		field := fmt.Sprintf("security.authorizedRequests[%v]", i)
+		v.urls(field+".urls", req.Urls)
+		v.oneOf(field+".access", string(req.Access), string(constant.AccessPermitAll), string(constant.AccessDenyAll),
+			string(constant.AccessHasRole), string(constant.AccessHasPermission), string(constant.AccessCustom))
+		if req.Access == constant.AccessHasRole && len(req.Roles) == 0 {
+			v.addf(field+".roles", "is required with access %v", req.Access)
+		}
+		if req.Access == constant.AccessHasPermission && len(req.Permissions) == 0 {
+			v.addf(field+".permissions", "is required with access %v", req.Access)
+		}
+	}
+
+	v.positive("job.workers", c.Job.Workers)
+	v.positive("job.maxAttempts", c.Job.MaxAttempts)
+	v.positive("job.pollInterval", c.Job.PollInterval)
+	v.positive("job.backoffBase", c.Job.BackoffBase)
+	v.positive("job.backoffMax", c.Job.BackoffMax)
+	if c.Job.BackoffMax < c.Job.BackoffBase {
+		v.addf("job.backoffMax", "must be at least job.backoffBase (%v)", c.Job.BackoffBase)
+	}
+	v.positive("job.leaseTimeout", c.Job.LeaseTimeout)
+	v.positive("job.retention", c.Job.Retention)
+	for _, jobType := range sortedKeys(c.Job.Concurrency) {
+		v.positive("job.concurrency."+jobType, c.Job.Concurrency[jobType])
+	}
+	for _, name := range sortedKeys(c.Job.Schedules) {
+		s := c.Job.Schedules[name]
+		v.required("job.schedules."+name+".type", s.Type)
+		if _, err := cron.ParseStandard(s.Cron); err != nil {
+			v.addf("job.schedules."+name+".cron", "%v", err)
+		}
+	}
+
+	v.positive("webhook.timeout", c.Webhook.Timeout)
+	v.positive("webhook.maxAttempts", c.Webhook.MaxAttempts)
+	v.positive("webhook.disableAfter", c.Webhook.DisableAfter)
+
+	v.oneOf("cache.store", c.Cache.Store, constant.CacheStoreMemory)
+	v.positive("cache.size", c.Cache.Size)
+	v.positive("cache.ttl", c.Cache.Ttl)
+	v.positive("cache.primaryWindow", c.Cache.PrimaryWindow)
+
+	v.oneOf("rateLimit.store", c.RateLimit.Store, constant.RateLimitStoreMemory)
+	for i, p := range c.RateLimit.Policies {
+		field := fmt.Sprintf("rateLimit.policies[%v]", i)
+		v.urls(field+".urls", p.Urls)
+		v.oneOf(field+".key", p.Key, constant.RateLimitKeyIP, constant.RateLimitKeyUser, constant.RateLimitKeyRoute)
+		v.positive(field+".rate", p.Rate)
+		v.positive(field+".period", p.Period)
+		v.positive(field+".burst", p.Burst)
+	}
+
+	v.positive("idempotency.ttl", c.Idempotency.Ttl)
+
+	if phonenumbers.GetCountryCodeForRegion(c.Validation.PhoneRegion) == 0 {
+		v.addf("validation.phoneRegion", "unknown region %q", c.Validation.PhoneRegion)
+	}
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+		v.addf("log.level", "unknown level %q", c.Log.Level)
+	}
+	v.oneOf("log.format", c.Log.Format, constant.LogFormatJSON, constant.LogFormatConsole)
+	v.positive("log.slowQueryThreshold", c.Log.SlowQueryThreshold)
+
+	if c.Tracing.Enabled {
+		v.oneOf("tracing.exporter", c.Tracing.Exporter, constant.TracingExporterOTLP, constant.TracingExporterStdout)
+		if c.Tracing.Exporter == constant.TracingExporterOTLP {
+			v.required("tracing.endpoint", c.Tracing.Endpoint)
+		}
+		if c.Tracing.SampleRatio <= 0 || c.Tracing.SampleRatio > 1 {
+			v.addf("tracing.sampleRatio", "must be greater than 0 and at most 1, got %v", c.Tracing.SampleRatio)
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (c DatabaseConfig) validate(v *validator, field string) {
+	v.oneOf(field+".driver", c.Driver, constant.DriverMySQL, constant.DriverPostgres, constant.DriverSQLite)
+	v.required(field+".dbname", c.Dbname)
+	if c.Driver != constant.DriverSQLite {
+		v.required(field+".host", c.Host)
+	}
+	if c.MaxIdleConns < 0 {
+		v.addf(field+".maxIdleConns", "must not be negative")
+	}
+	if c.MaxOpenConns < 0 {
+		v.addf(field+".maxOpenConns", "must not be negative")
+	}
+	v.positive(field+".connMaxLifetime", c.ConnMaxLifetime)
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(field string, format string, args ...interface{}) {
+	v.problems = append(v.problems, field+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf(field, "is required")
+	}
+}
+
+func (v *validator) oneOf(field string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf(field, "must be one of %v, got %q", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) positive(field string, value interface{}) {
+	switch value := value.(type) {
+	case int:
+		if value <= 0 {
+			v.addf(field, "must be positive, got %v", value)
+		}
+	case time.Duration:
+		if value <= 0 {
+			v.addf(field, "must be a positive duration, e.g. 30s or 1h, got %v", value)
+		}
+	}
+}
+
+// urls checks the request patterns of the security and rate limit rules.
+func (v *validator) urls(field string, urls []string) {
+	if len(urls) == 0 {
+		v.addf(field, "is required")
+	}
+	for _, u := range urls {
+		if parts := strings.Split(u, ":"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			v.addf(field, "%q must be <path pattern>:<method pattern>, e.g. /api/v1/**:GET", u)
+		}
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
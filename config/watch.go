@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// changes within this delay are reloaded at once, editors often write a file in
+// several steps
+const reloadDelay = 200 * time.Millisecond
+
+// Reloadable is a component applying the part of the config that can change without a
+// restart: log.level, cors, security, rateLimit and i18n.
+type Reloadable interface {
+	// Reload applies c, the component is left unchanged when it fails.
+	Reload(c Config) error
+}
+
+// ReloadFunc is a Reloadable function.
+type ReloadFunc func(c Config) error
+
+func (f ReloadFunc) Reload(c Config) error {
+	return f(c)
+}
+
+// Watcher reloads the config when a file of the config directory, or of the other
+// watched directories, changes. A new config is validated then applied to every
+// component, or rolled back when one of them fails, so they always share the same
+// config. Changes of the other sections are ignored until the next restart.
+type Watcher struct {
+	mu         sync.Mutex
+	current    Config
+	components []Reloadable
+	watcher    *fsnotify.Watcher
+	timer      *time.Timer
+	configDir  string
+	// watched directories other than the config one changed since the last reload
+	changedDirs map[string]bool
+}
+
+func NewWatcher(c Config) *Watcher {
+	return &Watcher{current: c, changedDirs: make(map[string]bool)}
+}
+
+// Watch applies the current config to components, then reloads them on every change
+// of the config files or of the files of dirs, e.g. the message files.
+func (w *Watcher) Watch(components []Reloadable, dirs ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, component := range components {
+		if err := component.Reload(w.current); err != nil {
+			return fmt.Errorf("apply config to %T: %w", component, err)
+		}
+	}
+	w.components = components
+
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// directories are watched rather than files, since files replaced by a rename,
+	// e.g. a mounted ConfigMap, would no longer be watched
+	for _, d := range append([]string{dir}, dirs...) {
+		if err = watcher.Add(d); err != nil {
+			watcher.Close()
+			return fmt.Errorf("watch %v: %w", d, err)
+		}
+	}
+	w.watcher = watcher
+	w.configDir = dir
+	go w.run()
+	return nil
+}
+
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if w.watcher == nil {
+		return nil
+	}
+	return w.watcher.Close()
+}
+
+func (w *Watcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			w.mu.Lock()
+			if dir := filepath.Dir(event.Name); dir != w.configDir {
+				w.changedDirs[dir] = true
+			}
+			if w.timer == nil {
+				w.timer = time.AfterFunc(reloadDelay, w.Reload)
+			} else {
+				w.timer.Reset(reloadDelay)
+			}
+			w.mu.Unlock()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Error().Err(err).Msg("Config watcher error")
+		}
+	}
+}
+
+// Reload loads the config again and applies its reloadable sections to the components,
+// an invalid config or a failing component leaves them on the current config.
+func (w *Watcher) Reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dirs := make([]string, 0, len(w.changedDirs))
+	for dir := range w.changedDirs {
+		dirs = append(dirs, dir)
+	}
+	w.changedDirs = make(map[string]bool)
+	loaded, err := LoadConfig()
+	if err != nil {
+		log.Error().Err(err).Str("event", "config.reload").Str("result", "rejected").
+			Msg("Config reload rejected, keeping the current config")
+		return
+	}
+	next, changed, ignored := w.current.reloaded(loaded)
+	if len(ignored) > 0 {
+		log.Warn().Strs("sections", ignored).Msg("Config changes ignored until the next restart")
+	}
+	// the files of the other directories, e.g. the messages, are read by the components
+	if len(changed) == 0 && len(dirs) == 0 {
+		return
+	}
+	for i, component := range w.components {
+		if err = component.Reload(next); err != nil {
+			w.rollback(w.components[:i])
+			log.Error().Err(err).Str("event", "config.reload").Str("result", "rolled_back").
+				Strs("sections", changed).Strs("dirs", dirs).Msgf("Config reload failed on %T, rolled back to the current config", component)
+			return
+		}
+	}
+	w.current = next
+	log.Info().Str("event", "config.reload").Str("result", "applied").
+		Strs("sections", changed).Strs("dirs", dirs).Msg("Config reloaded")
+}
+
+func (w *Watcher) rollback(components []Reloadable) {
+	for _, component := range components {
+		if err := component.Reload(w.current); err != nil {
+			log.Error().Err(err).Msgf("Can't roll back the config of %T", component)
+		}
+	}
+}
+
+// reloaded returns the current config with the reloadable sections of loaded, along
+// with the changed sections and the ignored ones.
+func (c Config) reloaded(loaded Config) (next Config, changed []string, ignored []string) {
+	next = c
+	next.CORS = loaded.CORS
+	next.Security = loaded.Security
+	next.RateLimit = loaded.RateLimit
+	next.I18n = loaded.I18n
+	next.Log.Level = loaded.Log.Level
+	return next, diffSections(c, next), diffSections(next, loaded)
+}
+
+// diffSections returns the yaml names of the top level sections differing in a and b.
+func diffSections(a Config, b Config) []string {
+	var sections []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			name := strings.Split(va.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = strings.ToLower(va.Type().Field(i).Name)
+			}
+			sections = append(sections, name)
+		}
+	}
+	return sections
+}
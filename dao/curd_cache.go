@@ -0,0 +1,109 @@
+package dao
+
+import (
+	"context"
+	"crypto/sha256"
+	"demo-curd/cache"
+	"demo-curd/config"
+	"demo-curd/database"
+	"demo-curd/model"
+	"demo-curd/util/ctxutil"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// the pages of each tenant are invalidated together, see curdListNamespace
+const curdListNamespacePrefix = "curd:list"
+
+// CachedCurdDao reads the curds of the tenant of ctx through the cache, the keys
+// include the tenant. Writes go through CurdDao and must call Invalidate once
+// committed, otherwise the readers see the old values until the ttl expires. For
+// primaryWindow after Invalidate the cache is filled from the primary, a lagging
+// replica would put the old values back, the other fills read the replicas.
+type CachedCurdDao struct {
+	CurdDao       *CurdDao
+	Cache         cache.Cache
+	ttl           time.Duration
+	primaryWindow time.Duration
+}
+
+// curd page as cached
+type cachedCurdPage struct {
+	Items []model.Curd
+	Total int64
+}
+
+func NewCachedCurdDao(c config.Config, curdDao *CurdDao, curdCache cache.Cache) *CachedCurdDao {
+	return &CachedCurdDao{
+		CurdDao:       curdDao,
+		Cache:         curdCache,
+		ttl:           c.Cache.Ttl,
+		primaryWindow: c.Cache.PrimaryWindow,
+	}
+}
+
+// FindByID returns nil without error when the record doesn't exist, misses aren't cached.
+func (r *CachedCurdDao) FindByID(ctx context.Context, id uint64) (*model.Curd, error) {
+	return cache.GetOrLoad(ctx, r.Cache, curdKey(ctx, id), r.ttl, func() (*model.Curd, error) {
+		return r.CurdDao.FindByID(r.fillContext(ctx), id)
+	})
+}
+
+// ListPage returns a page of the curds matching filter and their total, query identifies
+// filter and page in the cache, e.g. their values formatted with %#v.
+func (r *CachedCurdDao) ListPage(ctx context.Context, query string, filter Scope, page Scope) ([]model.Curd, int64, error) {
+	namespace := curdListNamespace(ctx)
+	version, err := cache.Version(ctx, r.Cache, namespace)
+	if err != nil {
+		version = ""
+	}
+	hash := sha256.Sum256([]byte(query))
+	key := fmt.Sprintf("%v:%v:%v", namespace, version, hex.EncodeToString(hash[:]))
+	res, err := cache.GetOrLoad(ctx, r.Cache, key, r.ttl, func() (cachedCurdPage, error) {
+		ctx := r.fillContext(ctx)
+		total, err := r.CurdDao.Count(ctx, filter)
+		if err != nil {
+			return cachedCurdPage{}, err
+		}
+		items, err := r.CurdDao.List(ctx, filter, page)
+		return cachedCurdPage{Items: items, Total: total}, err
+	})
+	return res.Items, res.Total, err
+}
+
+// Invalidate forgets the curds of ids and every cached page of the tenant of ctx.
+func (r *CachedCurdDao) Invalidate(ctx context.Context, ids ...uint64) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = curdKey(ctx, id)
+	}
+	if err := r.Cache.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	if _, err := cache.Bump(ctx, r.Cache, curdListNamespace(ctx)); err != nil {
+		return err
+	}
+	return r.Cache.Set(ctx, curdPrimaryKey(ctx), []byte("1"), r.primaryWindow)
+}
+
+// fillContext reads from the primary within primaryWindow of the last Invalidate of
+// the tenant. The disabled cache keeps nothing, so its reads always go to the replicas.
+func (r *CachedCurdDao) fillContext(ctx context.Context) context.Context {
+	if _, ok, err := r.Cache.Get(ctx, curdPrimaryKey(ctx)); err == nil && ok {
+		return database.ContextWithPrimary(ctx)
+	}
+	return ctx
+}
+
+func curdKey(ctx context.Context, id uint64) string {
+	return fmt.Sprintf("curd:%v:%v", ctxutil.GetTenantFromCtx(ctx), id)
+}
+
+func curdPrimaryKey(ctx context.Context) string {
+	return "curd:primary:" + ctxutil.GetTenantFromCtx(ctx)
+}
+
+func curdListNamespace(ctx context.Context) string {
+	return curdListNamespacePrefix + ":" + ctxutil.GetTenantFromCtx(ctx)
+}
@@ -1,62 +1,117 @@
 package dao
 
 import (
+	"context"
 	"demo-curd/database"
 	"demo-curd/model"
-	"demo-curd/util"
+	"demo-curd/util/ctxutil"
 	"errors"
 	"gorm.io/gorm"
 )
 
+// CurdDao scopes the lookups, lists, counts, updates and deletes to the tenant of ctx,
+// a curd of another tenant is never found.
 type CurdDao struct {
-	Db *database.Database
+	Repository[model.Curd]
 }
 
-func (r CurdDao) Create(curd *model.Curd) (*model.Curd, error) {
-	err := r.Db.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(curd).Error; err != nil {
-			return err
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return curd, nil
-}
-
-func (r CurdDao) UpdateDepartment(department *model.Curd) (*model.Curd, error) {
-	if err := r.Db.DB.Save(department).Error; err != nil {
-		return nil, err
+func NewCurdDao(db *database.Database) *CurdDao {
+	return &CurdDao{
+		Repository: NewRepository[model.Curd](db),
 	}
-	return department, nil
 }
 
-func (r CurdDao) DeleteDepartment(department *model.Curd) (*model.Curd, error) {
-	if err := r.Db.DB.Delete(department).Error; err != nil {
-		return nil, err
+// TenantScope restricts a query to the curds of the tenant of ctx.
+func TenantScope(ctx context.Context) Scope {
+	tenantId := ctxutil.GetTenantFromCtx(ctx)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tenant_id = ?", tenantId)
 	}
-	return department, nil
 }
 
-func (r CurdDao) GetDepartmentDetail(id uint64) (*model.Curd, error) {
+// FindByID returns nil without error when the curd doesn't exist in the tenant of ctx.
+func (r *CurdDao) FindByID(ctx context.Context, id uint64) (*model.Curd, error) {
 	var curd model.Curd
-	if err := r.Db.DB.Where("id = ?", id).First(&curd).Error; err != nil {
+	if err := r.Db.Conn(ctx).Scopes(TenantScope(ctx)).Where("id = ?", id).First(&curd).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
-		} else {
-			util.Must(err)
 		}
+		return nil, err
 	}
 	return &curd, nil
 }
 
-func (r CurdDao) List(page int, size int, sort string) (*[]model.Curd, error) {
+func (r *CurdDao) FindByIDs(ctx context.Context, ids []uint64) ([]model.Curd, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return r.Repository.List(ctx, TenantScope(ctx), func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ?", ids)
+	})
+}
+
+// PartialUpdate writes only the given columns and returns the number of updated rows.
+func (r *CurdDao) PartialUpdate(ctx context.Context, id uint64, columns map[string]interface{}) (int64, error) {
+	result := r.Db.Conn(ctx).Model(&model.Curd{}).Scopes(TenantScope(ctx)).Where("id = ?", id).Updates(columns)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByIDs returns the number of deleted rows.
+func (r *CurdDao) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.Db.Conn(ctx).Scopes(TenantScope(ctx)).Where("id IN ?", ids).Delete(&model.Curd{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *CurdDao) List(ctx context.Context, scopes ...Scope) ([]model.Curd, error) {
+	return r.Repository.List(ctx, append([]Scope{TenantScope(ctx)}, scopes...)...)
+}
+
+func (r *CurdDao) Each(ctx context.Context, fn func(curd *model.Curd) error, scopes ...Scope) error {
+	return r.Repository.Each(ctx, fn, append([]Scope{TenantScope(ctx)}, scopes...)...)
+}
+
+func (r *CurdDao) Count(ctx context.Context, scopes ...Scope) (int64, error) {
+	return r.Repository.Count(ctx, append([]Scope{TenantScope(ctx)}, scopes...)...)
+}
+
+func (r *CurdDao) Exists(ctx context.Context, scopes ...Scope) (bool, error) {
+	return r.Repository.Exists(ctx, append([]Scope{TenantScope(ctx)}, scopes...)...)
+}
+
+// Reindex recomputes the search text of every curd, e.g. for the rows written before
+// the column existed. It returns the number of updated rows.
+func (r *CurdDao) Reindex(ctx context.Context, batchSize int) (int64, error) {
+	var updated int64
+	var curds []model.Curd
+	err := r.Db.Conn(ctx).FindInBatches(&curds, batchSize, func(tx *gorm.DB, batch int) error {
+		for _, curd := range curds {
+			searchText := model.CurdSearchText(curd.Name, curd.Email, curd.Phone, curd.City)
+			if searchText == curd.SearchText {
+				continue
+			}
+			err := r.Db.Conn(ctx).Model(&model.Curd{}).Where("id = ?", curd.Id).
+				UpdateColumn("search_text", searchText).Error
+			if err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	}).Error
+	return updated, err
+}
+
+// FindByEmails returns the curds of the tenant having one of the emails, compared
+// ignoring case.
+func (r *CurdDao) FindByEmails(ctx context.Context, tenantId string, emails []string) ([]model.Curd, error) {
 	var curds []model.Curd
-	offset := (page - 1) * size
-	result := r.Db.DB.Offset(offset).Limit(size).Order(sort).Model(&model.Curd{}).Find(&curds)
-	if result.Error != nil {
-		return nil, result.Error
+	if len(emails) == 0 {
+		return curds, nil
 	}
-	return &curds, nil
+	err := r.Db.Conn(ctx).Select("id", "email").
+		Where("tenant_id = ? AND LOWER(email) IN ?", tenantId, emails).Find(&curds).Error
+	return curds, err
 }
@@ -0,0 +1,64 @@
+package dao
+
+import (
+	"context"
+	"demo-curd/database"
+	"demo-curd/model"
+	"demo-curd/util/ctxutil"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type CurdImportDao struct {
+	Repository[model.CurdImport]
+}
+
+func NewCurdImportDao(db *database.Database) *CurdImportDao {
+	return &CurdImportDao{
+		Repository: NewRepository[model.CurdImport](db),
+	}
+}
+
+// FindByImportID returns nil without error when the import doesn't exist, it's read
+// from the primary since the import is polled right after its creation.
+func (r *CurdImportDao) FindByImportID(ctx context.Context, id string) (*model.CurdImport, error) {
+	return r.find(database.ContextWithPrimary(ctx), func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", id)
+	})
+}
+
+// FindOwned is FindByImportID restricted to the imports of the tenant and the user of ctx.
+func (r *CurdImportDao) FindOwned(ctx context.Context, id string) (*model.CurdImport, error) {
+	return r.find(database.ContextWithPrimary(ctx), func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ? AND tenant_id = ? AND created_by = ?",
+			id, ctxutil.GetTenantFromCtx(ctx), ctxutil.GetUserFromCtx(ctx))
+	})
+}
+
+func (r *CurdImportDao) find(ctx context.Context, scope Scope) (*model.CurdImport, error) {
+	var curdImport model.CurdImport
+	if err := r.Db.Conn(ctx).Scopes(scope).First(&curdImport).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &curdImport, nil
+}
+
+// FinishedBefore returns the imports finished before t.
+func (r *CurdImportDao) FinishedBefore(ctx context.Context, t time.Time) ([]model.CurdImport, error) {
+	return r.List(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("finished_at < ?", t)
+	})
+}
+
+// DeleteByImportIDs returns the number of deleted rows.
+func (r *CurdImportDao) DeleteByImportIDs(ctx context.Context, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.Db.Conn(ctx).Where("id IN ?", ids).Delete(&model.CurdImport{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,143 @@
+package dao
+
+import (
+	"context"
+	"demo-curd/database"
+	"demo-curd/model"
+	"demo-curd/util/constant"
+	"demo-curd/util/dbutil"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"strings"
+)
+
+// CurdSearcher finds the curds of the tenant of ctx whose search text contains every
+// term, best matches first. Terms are normalized with textutil.Terms.
+type CurdSearcher interface {
+	Search(ctx context.Context, terms []string, offset int, limit int) ([]model.Curd, int64, error)
+}
+
+// NewCurdSearcher uses the FULLTEXT index on MySQL and ranks in process on the other
+// databases.
+func NewCurdSearcher(db *database.Database) CurdSearcher {
+	if db.DB.Dialector.Name() == constant.DriverMySQL {
+		return &fulltextCurdSearcher{Db: db}
+	}
+	return &scanCurdSearcher{Db: db}
+}
+
+// fulltextCurdSearcher ranks by the MySQL relevance of the ngram FULLTEXT index.
+type fulltextCurdSearcher struct {
+	Db *database.Database
+}
+
+func (r *fulltextCurdSearcher) Search(ctx context.Context, terms []string, offset int, limit int) ([]model.Curd, int64, error) {
+	// a term is searched as a phrase of ngrams, terms shorter than an ngram can't use the index
+	var phrases []string
+	var short []string
+	for _, term := range terms {
+		if len([]rune(term)) < 2 {
+			short = append(short, term)
+			continue
+		}
+		phrases = append(phrases, `+"`+term+`"`)
+	}
+	if len(phrases) == 0 {
+		return (&scanCurdSearcher{Db: r.Db}).Search(ctx, terms, offset, limit)
+	}
+	match := clause.Expr{SQL: "MATCH (search_text) AGAINST (? IN BOOLEAN MODE)", Vars: []interface{}{strings.Join(phrases, " ")}}
+	where := func(db *gorm.DB) *gorm.DB {
+		return db.Where(match).Scopes(TenantScope(ctx), containsAll(short))
+	}
+
+	var total int64
+	if err := r.Db.Conn(ctx).Model(&model.Curd{}).Scopes(where).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var curds []model.Curd
+	err := r.Db.Conn(ctx).Scopes(where).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "? DESC, id DESC", Vars: []interface{}{match}, WithoutParentheses: true}}).
+		Offset(offset).Limit(limit).Find(&curds).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return curds, total, nil
+}
+
+// scanCurdSearcher filters with LIKE and ranks the first SearchMaxCandidates matches
+// in process, a match at the start of the name ranks first. The matches past them
+// follow unranked, newest first.
+type scanCurdSearcher struct {
+	Db *database.Database
+}
+
+func (r *scanCurdSearcher) Search(ctx context.Context, terms []string, offset int, limit int) ([]model.Curd, int64, error) {
+	var total int64
+	if err := r.Db.Conn(ctx).Model(&model.Curd{}).Scopes(TenantScope(ctx), containsAll(terms)).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var candidates []model.Curd
+	err := r.Db.Conn(ctx).Scopes(TenantScope(ctx), containsAll(terms)).
+		Order("id DESC").Limit(constant.SearchMaxCandidates).Find(&candidates).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	scores := make(map[uint]int, len(candidates))
+	for _, curd := range candidates {
+		scores[curd.Id] = score(curd, terms)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i].Id] > scores[candidates[j].Id]
+	})
+	curds := []model.Curd{}
+	if offset < len(candidates) {
+		end := offset + limit
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		curds = append(curds, candidates[offset:end]...)
+	}
+	if len(curds) == limit || int64(offset+len(curds)) >= total {
+		return curds, total, nil
+	}
+	// past the ranked candidates, the candidates are the newest matches so the rest
+	// starts at the same position in the id order
+	var rest []model.Curd
+	err = r.Db.Conn(ctx).Scopes(TenantScope(ctx), containsAll(terms)).
+		Order("id DESC").Offset(offset + len(curds)).Limit(limit - len(curds)).Find(&rest).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return append(curds, rest...), total, nil
+}
+
+// score weights the matches by field and position.
+func score(curd model.Curd, terms []string) int {
+	name := model.CurdSearchText(curd.Name, "", "", "")
+	total := 0
+	for _, term := range terms {
+		switch {
+		case strings.HasPrefix(name, term):
+			total += 4
+		case strings.Contains(" "+name, " "+term):
+			total += 3
+		case strings.Contains(name, term):
+			total += 2
+		default:
+			total++
+		}
+	}
+	return total
+}
+
+// containsAll matches the terms literally, their LIKE wildcards are escaped.
+func containsAll(terms []string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, term := range terms {
+			db = db.Where(dbutil.ContainsLike("search_text", term))
+		}
+		return db
+	}
+}
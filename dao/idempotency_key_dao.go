@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"context"
+	"demo-curd/database"
+	"demo-curd/model"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"time"
+)
+
+// IdempotencyKeyDao stores the idempotency keys, it implements router.IdempotencyStore.
+// The unique index on the scope and the key lets a single request reserve a key even
+// across instances.
+type IdempotencyKeyDao struct {
+	Repository[model.IdempotencyKey]
+}
+
+func NewIdempotencyKeyDao(db *database.Database) *IdempotencyKeyDao {
+	return &IdempotencyKeyDao{
+		Repository: NewRepository[model.IdempotencyKey](db),
+	}
+}
+
+// Reserve inserts record unless its key is already used in its scope, in which case
+// the existing record is returned. Expired keys are replaced.
+func (r *IdempotencyKeyDao) Reserve(ctx context.Context, record *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	db := r.Db.Conn(ctx)
+	err := db.Where("scope = ? AND idempotency_key = ? AND expires_at < ?", record.Scope, record.Key, time.Now()).
+		Delete(&model.IdempotencyKey{}).Error
+	if err != nil {
+		return nil, err
+	}
+	createErr := db.Create(record).Error
+	if createErr == nil {
+		return nil, nil
+	}
+	// the insert failed, most likely on the unique index as drivers report it differently,
+	// the winner is read from the primary since replicas may not have it yet
+	var existing model.IdempotencyKey
+	err = db.Clauses(dbresolver.Write).Where("scope = ? AND idempotency_key = ?", record.Scope, record.Key).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, createErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Complete saves the response of a reserved key.
+func (r *IdempotencyKeyDao) Complete(ctx context.Context, record *model.IdempotencyKey) error {
+	return r.Db.Conn(ctx).Model(&model.IdempotencyKey{}).Where("id = ?", record.Id).
+		Updates(map[string]interface{}{
+			"completed":    true,
+			"status_code":  record.StatusCode,
+			"content_type": record.ContentType,
+			"response":     record.Response,
+		}).Error
+}
+
+// Release deletes a reserved key whose request failed, so it can be retried.
+func (r *IdempotencyKeyDao) Release(ctx context.Context, record *model.IdempotencyKey) error {
+	return r.Db.Conn(ctx).Where("id = ?", record.Id).Delete(&model.IdempotencyKey{}).Error
+}
+
+// DeleteExpired returns the number of deleted keys.
+func (r *IdempotencyKeyDao) DeleteExpired(ctx context.Context) (int64, error) {
+	result := r.Db.Conn(ctx).Where("expires_at < ?", time.Now()).Delete(&model.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
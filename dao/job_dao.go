@@ -0,0 +1,156 @@
+package dao
+
+import (
+	"context"
+	"demo-curd/database"
+	"demo-curd/model"
+	"demo-curd/util/constant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// JobDao implements the job queue. Jobs are claimed with a conditional update, so
+// concurrent workers of several instances never run the same job twice.
+type JobDao struct {
+	Repository[model.Job]
+}
+
+func NewJobDao(db *database.Database) *JobDao {
+	return &JobDao{
+		Repository: NewRepository[model.Job](db),
+	}
+}
+
+// Due returns up to limit pending jobs whose run time has come, skipping excludeTypes.
+func (r *JobDao) Due(ctx context.Context, limit int, excludeTypes []string) ([]model.Job, error) {
+	var jobs []model.Job
+	db := r.Db.Conn(ctx).Where("status = ? AND run_at <= ?", constant.JobStatusPending, time.Now())
+	if len(excludeTypes) > 0 {
+		db = db.Where("type NOT IN ?", excludeTypes)
+	}
+	if err := db.Order("run_at").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Claim marks a pending job as running by owner, it returns false when another
+// worker claimed it first. The attempts read by Due act as a version, a job run and
+// requeued meanwhile isn't claimed again from the stale read.
+func (r *JobDao) Claim(ctx context.Context, job *model.Job, owner string) (bool, error) {
+	now := time.Now()
+	result := r.Db.Conn(ctx).Model(&model.Job{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.Id, constant.JobStatusPending, job.Attempts).
+		Updates(map[string]interface{}{
+			"status":    constant.JobStatusRunning,
+			"attempts":  gorm.Expr("attempts + 1"),
+			"locked_by": owner,
+			"locked_at": now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	job.Status = constant.JobStatusRunning
+	job.Attempts++
+	job.LockedBy = owner
+	job.LockedAt = &now
+	return true, nil
+}
+
+// Heartbeat extends the lock of the jobs owner is still running.
+func (r *JobDao) Heartbeat(ctx context.Context, ids []uint64, owner string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.Db.Conn(ctx).Model(&model.Job{}).
+		Where("id IN ? AND status = ? AND locked_by = ?", ids, constant.JobStatusRunning, owner).
+		Update("locked_at", time.Now()).Error
+}
+
+// Release ends a run of owner with status, the update is skipped when the job was
+// cancelled or taken over meanwhile.
+func (r *JobDao) Release(ctx context.Context, id uint64, owner string, status string, runAt time.Time, lastError string) (bool, error) {
+	columns := map[string]interface{}{
+		"status":     status,
+		"locked_by":  "",
+		"locked_at":  nil,
+		"last_error": lastError,
+		"run_at":     runAt,
+	}
+	if status != constant.JobStatusPending {
+		columns["finished_at"] = time.Now()
+	}
+	result := r.Db.Conn(ctx).Model(&model.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", id, constant.JobStatusRunning, owner).
+		Updates(columns)
+	return result.RowsAffected > 0, result.Error
+}
+
+// RequeueStale gives back to the queue the running jobs not heartbeated since before,
+// e.g. when their instance crashed.
+func (r *JobDao) RequeueStale(ctx context.Context, before time.Time) (int64, error) {
+	result := r.Db.Conn(ctx).Model(&model.Job{}).
+		Where("status = ? AND locked_at < ?", constant.JobStatusRunning, before).
+		Updates(map[string]interface{}{
+			"status":    constant.JobStatusPending,
+			"locked_by": "",
+			"locked_at": nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Retry puts a failed or cancelled job back in the queue with its attempts reset.
+func (r *JobDao) Retry(ctx context.Context, id uint64) (bool, error) {
+	result := r.Db.Conn(ctx).Model(&model.Job{}).
+		Where("id = ? AND status IN ?", id, []string{constant.JobStatusFailed, constant.JobStatusCancelled}).
+		Updates(map[string]interface{}{
+			"status":      constant.JobStatusPending,
+			"attempts":    0,
+			"run_at":      time.Now(),
+			"finished_at": nil,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Cancel cancels a pending or running job.
+func (r *JobDao) Cancel(ctx context.Context, id uint64) (bool, error) {
+	result := r.Db.Conn(ctx).Model(&model.Job{}).
+		Where("id = ? AND status IN ?", id, []string{constant.JobStatusPending, constant.JobStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":      constant.JobStatusCancelled,
+			"locked_by":   "",
+			"locked_at":   nil,
+			"finished_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteFinished deletes the jobs finished before the given time.
+func (r *JobDao) DeleteFinished(ctx context.Context, before time.Time) (int64, error) {
+	result := r.Db.Conn(ctx).
+		Where("status IN ? AND finished_at < ?", []string{constant.JobStatusDone, constant.JobStatusFailed, constant.JobStatusCancelled}, before).
+		Delete(&model.Job{})
+	return result.RowsAffected, result.Error
+}
+
+// AcquireLock takes or renews the lock name for ttl, it returns false while another
+// owner holds an unexpired lease.
+func (r *JobDao) AcquireLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	db := r.Db.Conn(ctx)
+	result := db.Model(&model.JobLock{}).
+		Where("name = ? AND (owner = ? OR expires_at < ?)", name, owner, now).
+		Updates(map[string]interface{}{"owner": owner, "expires_at": now.Add(ttl)})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.RowsAffected > 0, result.Error
+	}
+	result = db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.JobLock{Name: name, Owner: owner, ExpiresAt: now.Add(ttl)})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseLock gives up the lock if owner holds it.
+func (r *JobDao) ReleaseLock(ctx context.Context, name string, owner string) error {
+	return r.Db.Conn(ctx).Where("name = ? AND owner = ?", name, owner).Delete(&model.JobLock{}).Error
+}
@@ -0,0 +1,146 @@
+package dao
+
+import (
+	"context"
+	"demo-curd/database"
+	"errors"
+	"gorm.io/gorm"
+)
+
+type Scope = func(db *gorm.DB) *gorm.DB
+
+// Repository implements the common CRUD operations of a model, DAOs embed it and
+// only add their model specific queries. Every method joins the transaction bound
+// to ctx, if any.
+type Repository[T any] struct {
+	Db *database.Database
+}
+
+func NewRepository[T any](db *database.Database) Repository[T] {
+	return Repository[T]{Db: db}
+}
+
+func (r Repository[T]) Create(ctx context.Context, entity *T) (*T, error) {
+	if err := r.Db.Conn(ctx).Create(entity).Error; err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// CreateInBatches inserts entities batchSize rows per statement.
+func (r Repository[T]) CreateInBatches(ctx context.Context, entities []T, batchSize int) ([]T, error) {
+	if len(entities) == 0 {
+		return entities, nil
+	}
+	if err := r.Db.Conn(ctx).CreateInBatches(&entities, batchSize).Error; err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// FindByID returns nil without error when the record doesn't exist.
+func (r Repository[T]) FindByID(ctx context.Context, id uint64) (*T, error) {
+	var entity T
+	if err := r.Db.Conn(ctx).Where("id = ?", id).First(&entity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entity, nil
+}
+
+func (r Repository[T]) FindByIDs(ctx context.Context, ids []uint64) ([]T, error) {
+	var entities []T
+	if len(ids) == 0 {
+		return entities, nil
+	}
+	if err := r.Db.Conn(ctx).Where("id IN ?", ids).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// Update writes all columns of entity.
+func (r Repository[T]) Update(ctx context.Context, entity *T) (*T, error) {
+	if err := r.Db.Conn(ctx).Save(entity).Error; err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// PartialUpdate writes only the given columns and returns the number of updated rows.
+func (r Repository[T]) PartialUpdate(ctx context.Context, id uint64, columns map[string]interface{}) (int64, error) {
+	result := r.Db.Conn(ctx).Model(new(T)).Where("id = ?", id).Updates(columns)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+func (r Repository[T]) Delete(ctx context.Context, entity *T) (*T, error) {
+	if err := r.Db.Conn(ctx).Delete(entity).Error; err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// DeleteByIDs returns the number of deleted rows.
+func (r Repository[T]) DeleteByIDs(ctx context.Context, ids []uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.Db.Conn(ctx).Where("id IN ?", ids).Delete(new(T))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// List returns the records matching scopes, e.g. dbutil.Pagination, dbutil.Equal or dbutil.Sort.
+func (r Repository[T]) List(ctx context.Context, scopes ...Scope) ([]T, error) {
+	var entities []T
+	if err := r.Db.Conn(ctx).Model(new(T)).Scopes(scopes...).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// Each calls fn for every record matching scopes. Rows are scanned one at a time so
+// large tables can be streamed without loading them in memory, an error returned by
+// fn stops the iteration.
+func (r Repository[T]) Each(ctx context.Context, fn func(entity *T) error, scopes ...Scope) error {
+	db := r.Db.Conn(ctx)
+	rows, err := db.Model(new(T)).Scopes(scopes...).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entity T
+		if err := db.ScanRows(rows, &entity); err != nil {
+			return err
+		}
+		if err := fn(&entity); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r Repository[T]) Count(ctx context.Context, scopes ...Scope) (int64, error) {
+	var count int64
+	if err := r.Db.Conn(ctx).Model(new(T)).Scopes(scopes...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r Repository[T]) Exists(ctx context.Context, scopes ...Scope) (bool, error) {
+	var found int
+	err := r.Db.Conn(ctx).Model(new(T)).Select("1").Scopes(scopes...).Limit(1).Scan(&found).Error
+	if err != nil {
+		return false, err
+	}
+	return found == 1, nil
+}
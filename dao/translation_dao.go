@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"context"
+	"demo-curd/database"
+	"demo-curd/i18n"
+	"demo-curd/model"
+	"errors"
+	"gorm.io/gorm"
+)
+
+// TranslationDao stores the messages edited by the admins, it implements
+// i18n.MessageStore.
+type TranslationDao struct {
+	Repository[model.Translation]
+}
+
+func NewTranslationDao(db *database.Database) *TranslationDao {
+	return &TranslationDao{
+		Repository: NewRepository[model.Translation](db),
+	}
+}
+
+// FindByMessageId returns nil without error when lang has no translation of messageId.
+func (r *TranslationDao) FindByMessageId(ctx context.Context, lang string, messageId string) (*model.Translation, error) {
+	var translation model.Translation
+	err := r.Db.Conn(ctx).Where("lang = ? AND message_id = ?", lang, messageId).First(&translation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &translation, nil
+}
+
+// Messages reads the translations from the primary, they're refreshed right after
+// being written.
+func (r *TranslationDao) Messages(ctx context.Context) (map[string][]*i18n.Message, error) {
+	messages := make(map[string][]*i18n.Message)
+	err := r.Each(database.ContextWithPrimary(ctx), func(t *model.Translation) error {
+		messages[t.Lang] = append(messages[t.Lang], &i18n.Message{ID: t.MessageId, One: t.One, Other: t.Other})
+		return nil
+	})
+	return messages, err
+}
@@ -0,0 +1,70 @@
+package dao
+
+import (
+	"context"
+	"demo-curd/database"
+	"demo-curd/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+type WebhookSubscriptionDao struct {
+	Repository[model.WebhookSubscription]
+}
+
+func NewWebhookSubscriptionDao(db *database.Database) *WebhookSubscriptionDao {
+	return &WebhookSubscriptionDao{
+		Repository: NewRepository[model.WebhookSubscription](db),
+	}
+}
+
+// FindActive returns the active subscriptions of eventType visible to tenantId.
+func (r *WebhookSubscriptionDao) FindActive(ctx context.Context, tenantId string, eventType string) ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+	err := r.Db.Conn(ctx).
+		Where("active = ? AND (tenant_id = '' OR tenant_id = ?)", true, tenantId).
+		Find(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+	matched := subscriptions[:0]
+	for _, s := range subscriptions {
+		if s.Subscribes(eventType) {
+			matched = append(matched, s)
+		}
+	}
+	return matched, nil
+}
+
+// RecordSuccess resets the consecutive failures of the subscription.
+func (r *WebhookSubscriptionDao) RecordSuccess(ctx context.Context, id uint64) error {
+	return r.Db.Conn(ctx).Model(&model.WebhookSubscription{}).
+		Where("id = ? AND failure_count > 0", id).
+		Update("failure_count", 0).Error
+}
+
+// RecordFailure counts a failed delivery and disables the subscription once
+// disableAfter deliveries failed in a row, it reports whether it was disabled.
+func (r *WebhookSubscriptionDao) RecordFailure(ctx context.Context, id uint64, disableAfter int) (bool, error) {
+	db := r.Db.Conn(ctx)
+	err := db.Model(&model.WebhookSubscription{}).
+		Where("id = ?", id).
+		Update("failure_count", gorm.Expr("failure_count + 1")).Error
+	if err != nil {
+		return false, err
+	}
+	result := db.Model(&model.WebhookSubscription{}).
+		Where("id = ? AND active = ? AND failure_count >= ?", id, true, disableAfter).
+		Updates(map[string]interface{}{"active": false, "disabled_at": time.Now()})
+	return result.RowsAffected > 0, result.Error
+}
+
+type WebhookDeliveryDao struct {
+	Repository[model.WebhookDelivery]
+}
+
+func NewWebhookDeliveryDao(db *database.Database) *WebhookDeliveryDao {
+	return &WebhookDeliveryDao{
+		Repository: NewRepository[model.WebhookDelivery](db),
+	}
+}
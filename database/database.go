@@ -2,12 +2,11 @@ package database
 
 import (
 	"demo-curd/config"
+	"demo-curd/logging"
+	"demo-curd/tracing"
 	"fmt"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-	"log"
-	"os"
+	"gorm.io/plugin/dbresolver"
 	"time"
 )
 
@@ -15,8 +14,12 @@ type Database struct {
 	DB *gorm.DB
 }
 
-func NewDatabase(c config.Config) (*Database, error) {
-	db, err := setupDatabase(c)
+// Datasources are additional named databases besides the primary one, configured
+// under database.datasources.
+type Datasources map[string]*Database
+
+func NewDatabase(c config.Config, t *tracing.Tracing) (*Database, error) {
+	db, err := setupDatabase(c.Database.DatabaseConfig, c.Log.SlowQueryThreshold, t, c.Database.Replicas...)
 	if err != nil {
 		return nil, err
 	}
@@ -24,42 +27,81 @@ func NewDatabase(c config.Config) (*Database, error) {
 
 }
 
-func setupDatabase(c config.Config) (*gorm.DB, error) {
-	// user:pass@tcp(127.0.0.1:3306)/dbname?charset=utf8mb4&parseTime=True&loc=Local
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", c.Database.Username, c.Database.Password, c.Database.Host,
-		c.Database.Port, c.Database.Dbname)
-	var err error
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+func NewDatasources(c config.Config, t *tracing.Tracing) (Datasources, error) {
+	datasources := make(Datasources, len(c.Database.Datasources))
+	for name, dc := range c.Database.Datasources {
+		db, err := setupDatabase(dc, c.Log.SlowQueryThreshold, t)
+		if err != nil {
+			datasources.Close()
+			return nil, fmt.Errorf("datasource %v: %w", name, err)
+		}
+		datasources[name] = &Database{DB: db}
+	}
+	return datasources, nil
+}
+
+func setupDatabase(c config.DatabaseConfig, slowThreshold time.Duration, t *tracing.Tracing, replicas ...config.DatabaseConfig) (*gorm.DB, error) {
+	dial, err := dialector(c)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dial, &gorm.Config{
 		SkipDefaultTransaction: true,
-		Logger: logger.New(
-			log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-			logger.Config{
-				SlowThreshold: time.Second, // Slow SQL threshold
-				LogLevel:      logger.Info, // Log level
-				Colorful:      true,        // Enable color
-			},
-		),
+		Logger:                 logging.NewGormLogger(slowThreshold),
 	})
 	if err != nil {
 		return nil, err
 	}
+	if err = db.Use(t.GormPlugin()); err != nil {
+		return nil, err
+	}
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
 	// SetMaxIdleConns sets the maximum number of connections in the idle connection pool.
-	sqlDB.SetMaxIdleConns(c.Database.MaxIdleConns)
+	sqlDB.SetMaxIdleConns(c.MaxIdleConns)
 
 	// SetMaxOpenConns sets the maximum number of open connections to the database.
-	sqlDB.SetMaxOpenConns(c.Database.MaxOpenConns)
+	sqlDB.SetMaxOpenConns(c.MaxOpenConns)
 
 	// SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
-	duration, _ := time.ParseDuration(c.Database.ConnMaxLifetime)
-	sqlDB.SetConnMaxLifetime(duration)
+	sqlDB.SetConnMaxLifetime(c.ConnMaxLifetime)
 
+	if len(replicas) > 0 {
+		if err = useReplicas(db, c, replicas); err != nil {
+			return nil, err
+		}
+	}
 	return db, nil
 }
 
+// useReplicas routes reads to the replicas, writes and everything inside a transaction
+// stay on the primary.
+func useReplicas(db *gorm.DB, primary config.DatabaseConfig, replicas []config.DatabaseConfig) error {
+	dialectors := make([]gorm.Dialector, 0, len(replicas))
+	for _, replica := range replicas {
+		dial, err := dialector(replica.Inherit(primary))
+		if err != nil {
+			return err
+		}
+		dialectors = append(dialectors, dial)
+	}
+	return db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxIdleConns(primary.MaxIdleConns).
+		SetMaxOpenConns(primary.MaxOpenConns).
+		SetConnMaxLifetime(primary.ConnMaxLifetime))
+}
+
+// Primary forces the following queries to the primary, e.g. to read your own writes
+// right after an update while replicas may still lag behind.
+func (r *Database) Primary() *gorm.DB {
+	return r.DB.Clauses(dbresolver.Write)
+}
+
 func (r *Database) Close() error {
 	sqlDB, err := r.DB.DB()
 	if err != nil {
@@ -70,3 +112,22 @@ func (r *Database) Close() error {
 	}
 	return nil
 }
+
+// Get returns the datasource with the given name.
+func (r Datasources) Get(name string) (*Database, error) {
+	db, ok := r[name]
+	if !ok {
+		return nil, fmt.Errorf("datasource %v is not configured", name)
+	}
+	return db, nil
+}
+
+func (r Datasources) Close() error {
+	var firstErr error
+	for _, db := range r {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
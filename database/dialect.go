@@ -0,0 +1,71 @@
+package database
+
+import (
+	"demo-curd/config"
+	"demo-curd/util/constant"
+	"fmt"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"net/url"
+	"strings"
+)
+
+// dialector builds the GORM dialector for the configured driver, mysql is the default.
+func dialector(c config.DatabaseConfig) (gorm.Dialector, error) {
+	switch strings.ToLower(c.Driver) {
+	case "", constant.DriverMySQL:
+		return mysql.Open(mysqlDsn(c)), nil
+	case constant.DriverPostgres:
+		return postgres.Open(postgresDsn(c)), nil
+	case constant.DriverSQLite:
+		return sqlite.Open(c.Dbname), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %v", c.Driver)
+	}
+}
+
+// user:pass@tcp(127.0.0.1:3306)/dbname?charset=utf8mb4&parseTime=True&loc=Local
+func mysqlDsn(c config.DatabaseConfig) string {
+	params := url.Values{}
+	params.Set("charset", valueOrDefault(c.Charset, constant.DefaultCharset))
+	params.Set("parseTime", "True")
+	params.Set("loc", valueOrDefault(c.Timezone, constant.DefaultTimezone))
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s", c.Username, c.Password, c.Host,
+		c.Port, c.Dbname, params.Encode())
+}
+
+// host=127.0.0.1 port=5432 user=postgres password=secret dbname=go_gin sslmode=disable TimeZone=Asia/Ho_Chi_Minh
+func postgresDsn(c config.DatabaseConfig) string {
+	params := []string{
+		"host=" + quoteDsnValue(c.Host),
+		"port=" + quoteDsnValue(c.Port),
+		"user=" + quoteDsnValue(c.Username),
+		"password=" + quoteDsnValue(c.Password),
+		"dbname=" + quoteDsnValue(c.Dbname),
+		"sslmode=" + quoteDsnValue(valueOrDefault(c.SslMode, constant.DefaultSslMode)),
+	}
+	// postgres doesn't know the "Local" location used by the mysql driver
+	if c.Timezone != "" && c.Timezone != constant.DefaultTimezone {
+		params = append(params, "TimeZone="+quoteDsnValue(c.Timezone))
+	}
+	return strings.Join(params, " ")
+}
+
+// quoteDsnValue quotes a libpq key/value connection string value when needed.
+func quoteDsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+func valueOrDefault(v string, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
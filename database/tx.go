@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// txKey is scoped per database so a transaction of one datasource is never used by another.
+type txKey struct {
+	db *Database
+}
+
+type primaryKey struct{}
+
+// ContextWithTx binds tx to ctx, DAOs of the same database pick it up through Conn.
+func ContextWithTx(ctx context.Context, db *Database, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{db: db}, tx)
+}
+
+// TxFromContext returns the transaction of db bound to ctx, if any.
+func TxFromContext(ctx context.Context, db *Database) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txKey{db: db}).(*gorm.DB)
+	return tx, ok
+}
+
+// ContextWithPrimary makes Conn read from the primary, e.g. to fill a cache right after
+// a write while the replicas may still return the old rows.
+func ContextWithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Conn returns the transaction bound to ctx, or the database itself when the call is
+// not part of a unit of work.
+func (r *Database) Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := TxFromContext(ctx, r); ok {
+		return tx
+	}
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return r.Primary().WithContext(ctx)
+	}
+	return r.DB.WithContext(ctx)
+}
@@ -0,0 +1,48 @@
+package request
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type BulkMode string
+
+const (
+	// BulkModeAllOrNothing writes nothing when any item fails.
+	BulkModeAllOrNothing BulkMode = "all_or_nothing"
+	// BulkModePartial writes the valid items and reports the failed ones.
+	BulkModePartial BulkMode = "partial"
+)
+
+// ParseBulkMode returns the mode of the mode query parameter, all_or_nothing when empty,
+// an unknown mode is a validation error of mode.
+func ParseBulkMode(mode string) (BulkMode, error) {
+	switch BulkMode(mode) {
+	case "", BulkModeAllOrNothing:
+		return BulkModeAllOrNothing, nil
+	case BulkModePartial:
+		return BulkModePartial, nil
+	default:
+		return "", validation.Errors{"mode": validation.ErrInInvalid}
+	}
+}
+
+type CurdBulkUpdateDTO struct {
+	Id uint64 `json:"id"`
+	CurdDTO
+}
+
+func (i CurdBulkUpdateDTO) Validate() error {
+	errs := validation.Errors{
+		"id": validation.Validate(i.Id, validation.Required),
+	}
+	if err := i.CurdDTO.Validate(); err != nil {
+		fieldErrs, ok := err.(validation.Errors)
+		if !ok {
+			return err
+		}
+		for field, fieldErr := range fieldErrs {
+			errs[field] = fieldErr
+		}
+	}
+	return errs.Filter()
+}
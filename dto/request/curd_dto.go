@@ -1,7 +1,12 @@
 package request
 
-import validation "github.com/go-ozzo/ozzo-validation/v4"
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
 
+// CurdDTO only holds the rules that need no context, the phone format, the city list
+// and the email uniqueness are checked by service.CurdValidator.
 type CurdDTO struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
@@ -11,5 +16,8 @@ type CurdDTO struct {
 
 func (i CurdDTO) Validate() error {
 	return validation.ValidateStruct(&i,
-		validation.Field(&i.Name, validation.Required, validation.Length(1, 100)))
+		validation.Field(&i.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&i.Email, validation.Length(0, 255), is.EmailFormat),
+		validation.Field(&i.Phone, validation.Length(0, 30)),
+		validation.Field(&i.City, validation.Length(0, 255)))
 }
@@ -0,0 +1,9 @@
+package request
+
+// CurdFilterDTO holds the query parameters shared by the list and export APIs.
+type CurdFilterDTO struct {
+	Name  string `form:"name"`
+	Email string `form:"email"`
+	Phone string `form:"phone"`
+	City  string `form:"city"`
+}
@@ -0,0 +1,6 @@
+package request
+
+type JobFilterDTO struct {
+	Type   string `form:"type"`
+	Status string `form:"status"`
+}
@@ -0,0 +1,50 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"strings"
+)
+
+const (
+	ContentTypeMergePatch = "application/merge-patch+json"
+	ContentTypeJSONPatch  = "application/json-patch+json"
+)
+
+var (
+	ErrPatchContentType = fmt.Errorf("the patch must be sent as %v or %v", ContentTypeMergePatch, ContentTypeJSONPatch)
+	ErrInvalidPatch     = errors.New("invalid patch")
+)
+
+// ApplyPatch applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902),
+// depending on contentType, to the json document of original and decodes the result
+// into patched. Fields unknown to patched are rejected.
+func ApplyPatch(contentType string, patch []byte, original interface{}, patched interface{}) error {
+	doc, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(contentType)) {
+	case ContentTypeMergePatch:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	case ContentTypeJSONPatch:
+		var operations jsonpatch.Patch
+		if operations, err = jsonpatch.DecodePatch(patch); err == nil {
+			doc, err = operations.Apply(doc)
+		}
+	default:
+		return ErrPatchContentType
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(patched); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return nil
+}
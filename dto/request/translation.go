@@ -0,0 +1,38 @@
+package request
+
+import (
+	"demo-curd/util/constant"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"text/template"
+)
+
+var ErrTemplateInvalid = validation.NewError("validation_template_invalid", "must be a valid template")
+
+type TranslationDTO struct {
+	Lang      string `json:"lang"`
+	MessageId string `json:"message_id"`
+	// singular form of the text, for the messages depending on a count
+	One   string `json:"one"`
+	Other string `json:"other"`
+}
+
+type TranslationFilterDTO struct {
+	Lang      string `form:"lang"`
+	MessageId string `form:"message_id"`
+}
+
+func (i TranslationDTO) Validate() error {
+	return validation.ValidateStruct(&i,
+		validation.Field(&i.Lang, validation.Required),
+		validation.Field(&i.MessageId, validation.Required, validation.Length(1, constant.I18nMessageIdMaxLength)),
+		validation.Field(&i.One, validation.By(validTemplate)),
+		validation.Field(&i.Other, validation.Required, validation.By(validTemplate)))
+}
+
+// validTemplate checks a text of the messages, they are go templates, e.g. {{.count}} items.
+func validTemplate(value interface{}) error {
+	if _, err := template.New("").Parse(value.(string)); err != nil {
+		return ErrTemplateInvalid
+	}
+	return nil
+}
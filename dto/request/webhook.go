@@ -0,0 +1,32 @@
+package request
+
+import (
+	"demo-curd/util/constant"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"regexp"
+)
+
+var webhookUrlPattern = regexp.MustCompile(`^https?://`)
+
+var webhookEventTypes = []interface{}{
+	constant.WebhookEventCurdCreated,
+	constant.WebhookEventCurdUpdated,
+	constant.WebhookEventCurdDeleted,
+	constant.WebhookEventAll,
+}
+
+type WebhookSubscriptionDTO struct {
+	Url string `json:"url"`
+	// signs the deliveries, generated when empty
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+}
+
+func (i WebhookSubscriptionDTO) Validate() error {
+	return validation.ValidateStruct(&i,
+		validation.Field(&i.Url, validation.Required, validation.Length(1, 2048), is.URL, validation.Match(webhookUrlPattern)),
+		validation.Field(&i.Secret, validation.Length(16, 255)),
+		validation.Field(&i.EventTypes, validation.Required, validation.Each(validation.In(webhookEventTypes...))))
+}
@@ -0,0 +1,74 @@
+package response
+
+type BulkResult struct {
+	Total     int              `json:"total"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Items     []BulkItemResult `json:"items"`
+}
+
+type BulkItemResult struct {
+	Index        int                  `json:"index"`
+	Id           uint                 `json:"id,omitempty"`
+	ErrorCode    string               `json:"error_code,omitempty"`
+	ErrorMessage string               `json:"error_msg,omitempty"`
+	ErrorFields  []ResponseErrorField `json:"error_fields,omitempty"`
+}
+
+func NewBulkResult(total int) *BulkResult {
+	items := make([]BulkItemResult, total)
+	for i := range items {
+		items[i].Index = i
+	}
+	return &BulkResult{
+		Total: total,
+		Items: items,
+	}
+}
+
+func (r *BulkResult) Succeed(index int, id uint) {
+	r.Items[index] = BulkItemResult{Index: index, Id: id}
+}
+
+func (r *BulkResult) Fail(index int, id uint, code string, err error) {
+	r.Items[index] = BulkItemResult{
+		Index:        index,
+		Id:           id,
+		ErrorCode:    code,
+		ErrorMessage: err.Error(),
+		ErrorFields:  ErrorFieldsOf(err),
+	}
+}
+
+// Abort marks every item that didn't fail as skipped, used when nothing was written
+// because of the failed ones.
+func (r *BulkResult) Abort(code string, err error) {
+	for i, item := range r.Items {
+		if item.ErrorCode == "" {
+			r.Items[i] = BulkItemResult{Index: i, Id: item.Id, ErrorCode: code, ErrorMessage: err.Error()}
+		}
+	}
+}
+
+func (r *BulkResult) SucceededIds() []uint64 {
+	ids := make([]uint64, 0, len(r.Items))
+	for _, item := range r.Items {
+		if item.ErrorCode == "" {
+			ids = append(ids, uint64(item.Id))
+		}
+	}
+	return ids
+}
+
+// Count recomputes Succeeded and Failed from the item results.
+func (r *BulkResult) Count() *BulkResult {
+	r.Succeeded, r.Failed = 0, 0
+	for _, item := range r.Items {
+		if item.ErrorCode == "" {
+			r.Succeeded++
+		} else {
+			r.Failed++
+		}
+	}
+	return r
+}
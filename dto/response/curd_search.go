@@ -0,0 +1,7 @@
+package response
+
+type CurdSearchHitDTO struct {
+	CurdDTO
+	// matched fragments of the fields wrapped in <em>, the text is html escaped
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
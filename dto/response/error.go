@@ -0,0 +1,82 @@
+package response
+
+import (
+	"errors"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"sort"
+)
+
+// ErrForbidden is the error of the requests the user isn't allowed to make, beyond the
+// security rules of the routes.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrConflict is the error of the requests conflicting with the state of a resource,
+// e.g. cancelling a finished job, see Conflict.
+var ErrConflict = errors.New("conflict")
+
+// ErrBadRequest is the error of the requests rejected as a whole, which have no field
+// to report a validation error on, see BadRequest.
+var ErrBadRequest = errors.New("bad request")
+
+type conflictError struct {
+	error
+}
+
+// Conflict marks err as an ErrConflict, answered with 409 and the message of err.
+func Conflict(err error) error {
+	return conflictError{err}
+}
+
+func (e conflictError) Unwrap() error {
+	return e.error
+}
+
+func (e conflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+type badRequestError struct {
+	error
+}
+
+// BadRequest marks err as an ErrBadRequest, answered with 400 and the message of err.
+func BadRequest(err error) error {
+	return badRequestError{err}
+}
+
+func (e badRequestError) Unwrap() error {
+	return e.error
+}
+
+func (e badRequestError) Is(target error) bool {
+	return target == ErrBadRequest
+}
+
+// ErrorFieldsOf converts the ozzo validation errors of a DTO into response error
+// fields, it returns nil for any other error.
+func ErrorFieldsOf(err error) []ResponseErrorField {
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	errorFields := make([]ResponseErrorField, 0, len(errs))
+	for _, field := range fields {
+		errorField := ResponseErrorField{
+			Field:        field,
+			ErrorMessage: errs[field].Error(),
+		}
+		var validationErr validation.Error
+		if errors.As(errs[field], &validationErr) {
+			errorField.Tag = validationErr.Code()
+			errorField.Params = validationErr.Params()
+		}
+		errorFields = append(errorFields, errorField)
+	}
+	return errorFields
+}
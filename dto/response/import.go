@@ -0,0 +1,18 @@
+package response
+
+import "time"
+
+type ImportJobDTO struct {
+	Id           string     `json:"id"`
+	FileName     string     `json:"file_name"`
+	DryRun       bool       `json:"dry_run"`
+	Status       string     `json:"status"`
+	StatusLabel  string     `json:"status_label"`
+	TotalRows    int        `json:"total_rows"`
+	ImportedRows int        `json:"imported_rows"`
+	RejectedRows int        `json:"rejected_rows"`
+	HasReport    bool       `json:"has_report"`
+	ErrorMessage string     `json:"error_msg,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
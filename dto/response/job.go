@@ -0,0 +1,22 @@
+package response
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type JobDTO struct {
+	Id          uint64          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload,omitempty" swaggertype:"object"`
+	Status      string          `json:"status"`
+	StatusLabel string          `json:"status_label"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedBy    string          `json:"locked_by,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
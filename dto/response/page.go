@@ -0,0 +1,8 @@
+package response
+
+type PageDTO struct {
+	Items interface{} `json:"items"`
+	Page  int         `json:"page"`
+	Size  int         `json:"size"`
+	Total int64       `json:"total"`
+}
@@ -11,4 +11,6 @@ type ResponseErrorField struct {
 	Field        string `json:"field,omitempty"`
 	Tag          string `json:"tag,omitempty"`
 	ErrorMessage string `json:"error_msg,omitempty"`
+	// template data of the message of Tag, e.g. min and max of a length
+	Params map[string]interface{} `json:"-"`
 }
@@ -0,0 +1,21 @@
+package response
+
+import "time"
+
+type TranslationDTO struct {
+	Id        uint64    `json:"id"`
+	Lang      string    `json:"lang"`
+	MessageId string    `json:"message_id"`
+	One       string    `json:"one,omitempty"`
+	Other     string    `json:"other"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TranslationImportDTO counts the messages of an imported file.
+type TranslationImportDTO struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	// same text as the current message
+	Unchanged int `json:"unchanged"`
+}
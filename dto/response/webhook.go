@@ -0,0 +1,41 @@
+package response
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookSubscriptionDTO struct {
+	Id       uint64 `json:"id"`
+	TenantId string `json:"tenant_id,omitempty"`
+	Url      string `json:"url"`
+	// only returned when the subscription is created
+	Secret       string     `json:"secret,omitempty"`
+	EventTypes   []string   `json:"event_types"`
+	Active       bool       `json:"active"`
+	FailureCount int        `json:"failure_count"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type WebhookDeliveryDTO struct {
+	Id         uint64    `json:"id"`
+	EventId    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookEventDTO is the body posted to the subscriptions.
+type WebhookEventDTO struct {
+	Id        string          `json:"id"`
+	Type      string          `json:"type"`
+	TenantId  string          `json:"tenant_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+}
@@ -1,74 +1,288 @@
 package i18n
 
 import (
-	"bytes"
+	"context"
 	"demo-curd/config"
+	"demo-curd/dto/response"
 	"demo-curd/util/constant"
-	"encoding/json"
-	"golang.org/x/text/language"
+	"errors"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"html/template"
-	"io/ioutil"
-	"path"
-	"strings"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type I18n struct {
-	Bundle       *i18n.Bundle
-	MapLocalizer map[string]*i18n.Localizer
+	// messages edited by the admins, nil when only the files are used
+	store MessageStore
+	// serializes the reloads, so a slower one never replaces a newer catalog
+	mu sync.Mutex
+	// config of the last reload
+	config config.Config
+	// *catalog of the last reload
+	catalog atomic.Value
+	cancel  context.CancelFunc
+	done    chan struct{}
 }
 
-func NewI18n(c config.Config) (*I18n, error) {
-	bundle := i18n.NewBundle(language.English)
-	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
-	mapLocalizer := make(map[string]*i18n.Localizer)
-	files, err := ioutil.ReadDir("i18n")
-	if err != nil {
+// Message is a message of the catalogs with its default text, used when the catalogs
+// don't have it. One and Other are the plural forms of the text, see Localize.
+type Message = i18n.Message
+
+// catalog holds the messages of the configured languages.
+type catalog struct {
+	bundle     *i18n.Bundle
+	localizers map[string]*i18n.Localizer
+	// configured languages, the default one first
+	langs    []string
+	messages messageSet
+	matcher  language.Matcher
+}
+
+// renders the default texts of the messages, e.g. in the logs
+var defaultLocalizer = i18n.NewLocalizer(i18n.NewBundle(language.Make(constant.DefaultLang)))
+
+// NewI18n loads the message files, the messages of store, if any, are only loaded by
+// the first Reload, once the database is migrated.
+func NewI18n(c config.Config, store MessageStore) (*I18n, error) {
+	r := &I18n{store: store}
+	if err := r.load(context.Background(), c, false); err != nil {
 		return nil, err
 	}
+	return r, nil
+}
+
+// Reload reads the message files of the languages of c and the messages of the store
+// again, the current messages are kept when a file is invalid or the store fails.
+func (r *I18n) Reload(c config.Config) error {
+	return r.load(context.Background(), c, true)
+}
+
+// Refresh reads the messages again with the config of the last reload, e.g. once the
+// messages of the store were edited.
+func (r *I18n) Refresh(ctx context.Context) error {
+	r.mu.Lock()
+	c := r.config
+	r.mu.Unlock()
+	return r.load(ctx, c, true)
+}
+
+func (r *I18n) load(ctx context.Context, c config.Config, withStore bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	langs := []string{constant.DefaultLang}
 	for _, lang := range c.I18n.Langs {
-		for _, file := range files {
-			if strings.HasSuffix(file.Name(), lang+".json") && !file.IsDir() {
-				bundle.MustLoadMessageFile(path.Join("i18n", file.Name()))
+		if lang != constant.DefaultLang {
+			langs = append(langs, lang)
+		}
+	}
+	var stored map[string][]*Message
+	if withStore && r.store != nil {
+		var err error
+		if stored, err = r.store.Messages(ctx); err != nil {
+			return err
+		}
+	}
+	bundle, messages, err := loadBundle(langs, c.I18n.Dir, stored)
+	if err != nil {
+		return err
+	}
+	localizers := make(map[string]*i18n.Localizer, len(langs))
+	tags := make([]language.Tag, len(langs))
+	for i, lang := range langs {
+		// the messages missing in lang fall back to the default language
+		localizers[lang] = i18n.NewLocalizer(bundle, lang, constant.DefaultLang)
+		tags[i] = language.Make(lang)
+	}
+	r.catalog.Store(&catalog{
+		bundle:     bundle,
+		localizers: localizers,
+		langs:      langs,
+		messages:   messages,
+		matcher:    language.NewMatcher(tags),
+	})
+	r.config = c
+	return nil
+}
+
+// Start refreshes the messages every i18n.refreshInterval until Stop, so the messages
+// edited on another instance are used here too.
+func (r *I18n) Start() {
+	if r.store == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel, r.done = cancel, make(chan struct{})
+	go func() {
+		defer close(r.done)
+		for {
+			r.mu.Lock()
+			interval := r.config.I18n.RefreshInterval
+			r.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			if err := r.Refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Msg("Can't refresh the messages, keeping the current ones")
 			}
 		}
-		mapLocalizer[lang] = i18n.NewLocalizer(bundle, lang)
+	}()
+}
+
+func (r *I18n) Stop() {
+	if r.cancel == nil {
+		return
 	}
+	r.cancel()
+	<-r.done
+}
 
-	return &I18n{
-		Bundle:       bundle,
-		MapLocalizer: mapLocalizer,
-	}, nil
+// Langs returns the configured languages, the default one first.
+func (r *I18n) Langs() []string {
+	return r.catalog.Load().(*catalog).langs
 }
 
-func (r *I18n) MustLocalize(lang string, msgId string, templateData map[string]string, defaultMsg ...string) string {
-	var localize *i18n.Localizer
-	localize, ok := r.MapLocalizer[lang]
+// Messages returns the messages of lang used by Localize, e.g. to export them, nil
+// when lang isn't configured.
+func (r *I18n) Messages(lang string) map[string]*Message {
+	return r.catalog.Load().(*catalog).messages[lang]
+}
+
+// Missing returns, by language, the sorted ids of the messages translated in another
+// language but not in this one, the languages translating every message are left out.
+func (r *I18n) Missing() map[string][]string {
+	catalog := r.catalog.Load().(*catalog)
+	all := make(map[string]bool)
+	for _, messages := range catalog.messages {
+		for id := range messages {
+			all[id] = true
+		}
+	}
+	missing := make(map[string][]string)
+	for _, lang := range catalog.langs {
+		for id := range all {
+			if _, ok := catalog.messages[lang][id]; !ok {
+				missing[lang] = append(missing[lang], id)
+			}
+		}
+		sort.Strings(missing[lang])
+	}
+	return missing
+}
+
+// Match returns the configured language best matching an Accept-Language header, e.g.
+// "vi" for "fr;q=1, vi-VN;q=0.8, en;q=0.5" when vi and en are configured, or the
+// default language when none matches.
+func (r *I18n) Match(acceptLanguage string) string {
+	catalog := r.catalog.Load().(*catalog)
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return constant.DefaultLang
+	}
+	_, i, confidence := catalog.matcher.Match(tags...)
+	if confidence == language.No {
+		return constant.DefaultLang
+	}
+	return catalog.langs[i]
+}
+
+// Localize renders message in lang, falling back to the default language then to the
+// default text of message. The plural form of the text is chosen by pluralCount when
+// not nil, e.g. {"one": "{{.count}} item", "other": "{{.count}} items"} in the files.
+func (r *I18n) Localize(lang string, message *Message, templateData map[string]interface{}, pluralCount interface{}) string {
+	localizers := r.catalog.Load().(*catalog).localizers
+	localizer, ok := localizers[lang]
 	if !ok {
-		localize = r.MapLocalizer[constant.DefaultLang]
+		localizer = localizers[constant.DefaultLang]
 	}
-	ret, err := localize.Localize(&i18n.LocalizeConfig{
-		MessageID:    msgId,
+	return localize(localizer, message, templateData, pluralCount)
+}
+
+func localize(localizer *i18n.Localizer, message *Message, templateData map[string]interface{}, pluralCount interface{}) string {
+	lc := &i18n.LocalizeConfig{
+		MessageID:    message.ID,
 		TemplateData: templateData,
-	})
-	if err != nil {
-		if len(defaultMsg) > 0 {
-			return bindingTemplate(msgId, defaultMsg[0], templateData)
-		} else {
-			return msgId
-		}
+		PluralCount:  pluralCount,
+	}
+	if message.Other != "" {
+		lc.DefaultMessage = message
+	}
+	// the text is rendered even when the message is missing in lang
+	ret, err := localizer.Localize(lc)
+	if ret == "" && err != nil {
+		return message.ID
 	}
 	return ret
 }
 
-func bindingTemplate(name string, msg string, data interface{}) string {
-	tmpl, err := template.New(name).Parse(msg)
-	if err != nil {
-		return msg
+func (r *I18n) MustLocalize(lang string, msgId string, templateData map[string]string, defaultMsg ...string) string {
+	message := &Message{ID: msgId}
+	if len(defaultMsg) > 0 {
+		message.Other = defaultMsg[0]
+	}
+	data := make(map[string]interface{}, len(templateData))
+	for k, v := range templateData {
+		data[k] = v
 	}
-	var buffer = bytes.NewBuffer(nil)
-	if err = tmpl.Execute(buffer, data); err != nil {
-		return msg
+	return r.Localize(lang, message, data, nil)
+}
+
+// LocalizeEnum returns the label of a value of an enum in lang, the message id is the
+// enum followed by the value, e.g. job.status.pending, the value when untranslated.
+func (r *I18n) LocalizeEnum(lang string, enum string, value string) string {
+	return r.Localize(lang, &Message{ID: enum + "." + value, Other: value}, nil, nil)
+}
+
+// LocalizeErrorFields translates the messages of the validation error fields, the
+// message id is the error tag, e.g. validation_required, with the error params as
+// template data and max, or min, as plural count. Untranslated messages are left as is.
+func (r *I18n) LocalizeErrorFields(lang string, fields []response.ResponseErrorField) {
+	for i, field := range fields {
+		if field.Tag == "" {
+			continue
+		}
+		var pluralCount interface{}
+		if max, ok := field.Params["max"]; ok {
+			pluralCount = max
+		} else if min, ok := field.Params["min"]; ok {
+			pluralCount = min
+		}
+		message := &Message{ID: field.Tag, Other: field.ErrorMessage}
+		fields[i].ErrorMessage = r.Localize(lang, message, field.Params, pluralCount)
+	}
+}
+
+// Error is an error whose message is rendered in the language of the request by
+// router.ErrorMiddleware, Error returns the default text.
+type Error struct {
+	Message      *Message
+	TemplateData map[string]interface{}
+	PluralCount  interface{}
+}
+
+func NewError(message *Message, templateData map[string]interface{}, pluralCount interface{}) *Error {
+	return &Error{
+		Message:      message,
+		TemplateData: templateData,
+		PluralCount:  pluralCount,
+	}
+}
+
+func (e *Error) Error() string {
+	return localize(defaultLocalizer, e.Message, e.TemplateData, e.PluralCount)
+}
+
+// LocalizeError renders the message of err in lang when err is, or wraps, an Error and
+// returns err.Error() otherwise.
+func (r *I18n) LocalizeError(lang string, err error) string {
+	var localized *Error
+	if !errors.As(err, &localized) {
+		return err.Error()
 	}
-	return buffer.String()
+	return r.Localize(lang, localized.Message, localized.TemplateData, localized.PluralCount)
 }
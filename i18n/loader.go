@@ -0,0 +1,128 @@
+package i18n
+
+import (
+	"context"
+	"demo-curd/util/constant"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v2"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// the message files shipped in the binary, so it runs from any directory
+//
+//go:embed messages.*
+var embedded embed.FS
+
+// formats of the message files by extension
+var unmarshalFuncs = map[string]i18n.UnmarshalFunc{
+	"json": json.Unmarshal,
+	"yaml": yaml.Unmarshal,
+	"yml":  yaml.Unmarshal,
+	"toml": toml.Unmarshal,
+}
+
+// MessageStore keeps the messages edited by the admins, they replace the messages of
+// the files with the same id and language.
+type MessageStore interface {
+	// Messages returns the messages by language.
+	Messages(ctx context.Context) (map[string][]*Message, error)
+}
+
+type messageSource struct {
+	name  string
+	files fs.FS
+}
+
+// messages by language then id
+type messageSet map[string]map[string]*Message
+
+func (s messageSet) add(lang string, messages []*Message) {
+	for _, message := range messages {
+		s[lang][message.ID] = message
+	}
+}
+
+// loadBundle loads the messages of langs in layers, each one replacing the messages of
+// the previous ones: the embedded files, the files of dir, if any, then stored, the
+// messages of the store. It returns the messages of each language along with the bundle.
+func loadBundle(langs []string, dir string, stored map[string][]*Message) (*i18n.Bundle, messageSet, error) {
+	bundle := i18n.NewBundle(language.Make(constant.DefaultLang))
+	for format, unmarshal := range unmarshalFuncs {
+		bundle.RegisterUnmarshalFunc(format, unmarshal)
+	}
+	messages := make(messageSet, len(langs))
+	for _, lang := range langs {
+		messages[lang] = make(map[string]*Message)
+	}
+	sources := []messageSource{{"embedded", embedded}}
+	if dir != "" {
+		sources = append(sources, messageSource{dir, os.DirFS(dir)})
+	}
+	for _, source := range sources {
+		if err := loadMessageFiles(bundle, source, messages); err != nil {
+			return nil, nil, err
+		}
+	}
+	for lang, langMessages := range stored {
+		if messages[lang] == nil {
+			continue
+		}
+		if err := bundle.AddMessages(language.Make(lang), langMessages...); err != nil {
+			return nil, nil, fmt.Errorf("add stored messages of %v: %w", lang, err)
+		}
+		messages.add(lang, langMessages)
+	}
+	return bundle, messages, nil
+}
+
+// loadMessageFiles loads the files of source named <name>.<lang>.<format>, e.g.
+// messages.vi.yaml, of the languages of messages.
+func loadMessageFiles(bundle *i18n.Bundle, source messageSource, messages messageSet) error {
+	entries, err := fs.ReadDir(source.files, ".")
+	if err != nil {
+		return fmt.Errorf("read message files of %v: %w", source.name, err)
+	}
+	for _, entry := range entries {
+		parts := strings.Split(entry.Name(), ".")
+		if entry.IsDir() || len(parts) < 3 {
+			continue
+		}
+		lang, format := language.Make(parts[len(parts)-2]).String(), parts[len(parts)-1]
+		if _, ok := unmarshalFuncs[format]; !ok || messages[lang] == nil {
+			continue
+		}
+		data, err := fs.ReadFile(source.files, entry.Name())
+		if err != nil {
+			return fmt.Errorf("read %v of %v: %w", entry.Name(), source.name, err)
+		}
+		file, err := bundle.ParseMessageFileBytes(data, entry.Name())
+		if err != nil {
+			return fmt.Errorf("parse %v of %v: %w", entry.Name(), source.name, err)
+		}
+		messages.add(lang, file.Messages)
+	}
+	return nil
+}
+
+// ParseMessages parses the messages of lang in the format of the message files, e.g.
+// {"id": "text", "plural id": {"one": "text", "other": "texts"}} for json.
+func ParseMessages(lang string, format string, data []byte) ([]*Message, error) {
+	unmarshal, ok := unmarshalFuncs[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported message format %v", format)
+	}
+	bundle := i18n.NewBundle(language.Make(constant.DefaultLang))
+	bundle.RegisterUnmarshalFunc(format, unmarshal)
+	file, err := bundle.ParseMessageFileBytes(data, constant.I18nMessage+"."+lang+"."+format)
+	if err != nil {
+		return nil, err
+	}
+	return file.Messages, nil
+}
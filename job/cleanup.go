@@ -0,0 +1,22 @@
+package job
+
+import (
+	"context"
+	"demo-curd/dao"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const CleanupType = "job.cleanup"
+
+// NewCleanupHandler deletes the jobs finished for longer than retention.
+func NewCleanupHandler(jobDao *dao.JobDao, retention time.Duration) Handler {
+	return HandlerFunc(CleanupType, func(ctx context.Context, _ struct{}) error {
+		deleted, err := jobDao.DeleteFinished(ctx, time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		log.Ctx(ctx).Info().Msgf("Deleted %v finished jobs", deleted)
+		return nil
+	})
+}
@@ -0,0 +1,37 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Handler runs the jobs of a type. A returned error is retried with exponential
+// backoff until the max attempts of the job are used.
+type Handler interface {
+	Type() string
+	Handle(ctx context.Context, payload []byte) error
+}
+
+type handlerFunc[P any] struct {
+	jobType string
+	fn      func(ctx context.Context, payload P) error
+}
+
+// HandlerFunc adapts fn to a Handler of jobType, the json payload is decoded into P.
+func HandlerFunc[P any](jobType string, fn func(ctx context.Context, payload P) error) Handler {
+	return handlerFunc[P]{jobType: jobType, fn: fn}
+}
+
+func (h handlerFunc[P]) Type() string {
+	return h.jobType
+}
+
+func (h handlerFunc[P]) Handle(ctx context.Context, payload []byte) error {
+	var p P
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+	}
+	return h.fn(ctx, p)
+}
@@ -0,0 +1,381 @@
+package job
+
+import (
+	"context"
+	"demo-curd/config"
+	"demo-curd/dao"
+	"demo-curd/model"
+	"demo-curd/util/constant"
+	"encoding/json"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Manager runs the jobs of the database queue with a pool of workers and enqueues
+// the configured cron schedules. Every instance runs jobs, only the instance holding
+// the scheduler lock enqueues the schedules.
+type Manager struct {
+	JobDao   *dao.JobDao
+	Options  Options
+	owner    string
+	handlers map[string]Handler
+	cron     *cron.Cron
+	leader   int32
+	mu       sync.Mutex
+	running  map[uint64]runningJob
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+type runningJob struct {
+	jobType string
+	cancel  context.CancelFunc
+}
+
+// Option customizes an enqueued job.
+type Option func(job *model.Job)
+
+// Delay postpones the first run of the job.
+func Delay(d time.Duration) Option {
+	return func(job *model.Job) {
+		job.RunAt = job.RunAt.Add(d)
+	}
+}
+
+// MaxAttempts overrides the configured max attempts of the job.
+func MaxAttempts(n int) Option {
+	return func(job *model.Job) {
+		job.MaxAttempts = n
+	}
+}
+
+func NewManager(c config.Config, jobDao *dao.JobDao, handlers []Handler) (*Manager, error) {
+	options := OptionsOf(c)
+	m := &Manager{
+		JobDao:   jobDao,
+		Options:  options,
+		owner:    newOwner(),
+		handlers: make(map[string]Handler, len(handlers)),
+		running:  make(map[uint64]runningJob),
+	}
+	for _, h := range handlers {
+		if err := m.Register(h); err != nil {
+			return nil, err
+		}
+	}
+	for name, s := range options.Schedules {
+		if _, ok := m.handlers[s.Type]; !ok {
+			return nil, fmt.Errorf("job schedule %v: no handler for type %v", name, s.Type)
+		}
+		if _, err := cron.ParseStandard(s.Cron); err != nil {
+			return nil, fmt.Errorf("job schedule %v: %w", name, err)
+		}
+		if s.Payload != "" && !json.Valid([]byte(s.Payload)) {
+			return nil, fmt.Errorf("job schedule %v: payload is not valid json", name)
+		}
+	}
+	return m, nil
+}
+
+// Register adds a handler after NewManager, for the services that enqueue jobs
+// themselves and so can't be built before the manager, e.g. the curd import publishing
+// webhook events. It must be called before Start.
+func (m *Manager) Register(h Handler) error {
+	if _, ok := m.handlers[h.Type()]; ok {
+		return fmt.Errorf("duplicate job handler: %v", h.Type())
+	}
+	m.handlers[h.Type()] = h
+	return nil
+}
+
+// Enqueue adds a job of jobType with the json encoded payload. The job joins the
+// transaction bound to ctx, so it's only run if the transaction commits.
+func (m *Manager) Enqueue(ctx context.Context, jobType string, payload interface{}, opts ...Option) (*model.Job, error) {
+	if _, ok := m.handlers[jobType]; !ok {
+		return nil, fmt.Errorf("no handler for job type %v", jobType)
+	}
+	job := &model.Job{
+		Type:        jobType,
+		Status:      constant.JobStatusPending,
+		MaxAttempts: m.Options.MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		job.Payload = string(b)
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	return m.JobDao.Create(ctx, job)
+}
+
+func (m *Manager) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.wg.Add(1)
+	go m.poll(ctx)
+
+	if len(m.Options.Schedules) == 0 {
+		return
+	}
+	m.cron = cron.New()
+	for name, s := range m.Options.Schedules {
+		name, s := name, s
+		// specs were validated by NewManager
+		_, _ = m.cron.AddFunc(s.Cron, func() {
+			m.fire(ctx, name, s)
+		})
+	}
+	m.cron.Start()
+	m.wg.Add(1)
+	go m.elect(ctx)
+}
+
+// Stop stops polling and waits for the running jobs, they are cancelled and given
+// back to the queue.
+func (m *Manager) Stop() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	if m.cron != nil {
+		<-m.cron.Stop().Done()
+	}
+	m.wg.Wait()
+	if atomic.LoadInt32(&m.leader) == 1 {
+		if err := m.JobDao.ReleaseLock(context.Background(), constant.JobSchedulerLockName, m.owner); err != nil {
+			log.Error().Err(err).Msg("Release job scheduler lock failed")
+		}
+	}
+}
+
+// Interrupt cancels the context of the job if it runs on this instance.
+func (m *Manager) Interrupt(id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.running[id]; ok {
+		r.cancel()
+	}
+}
+
+func (m *Manager) poll(ctx context.Context) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.Options.PollInterval)
+	defer ticker.Stop()
+	heartbeat := time.NewTicker(m.Options.LeaseTimeout / 3)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			m.heartbeat(ctx)
+		case <-ticker.C:
+			m.dispatch(ctx)
+		}
+	}
+}
+
+func (m *Manager) heartbeat(ctx context.Context) {
+	m.mu.Lock()
+	ids := make([]uint64, 0, len(m.running))
+	for id := range m.running {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+	if err := m.JobDao.Heartbeat(ctx, ids, m.owner); err != nil {
+		log.Error().Err(err).Msg("Job heartbeat failed")
+	}
+	requeued, err := m.JobDao.RequeueStale(ctx, time.Now().Add(-m.Options.LeaseTimeout))
+	if err != nil {
+		log.Error().Err(err).Msg("Requeue stale jobs failed")
+	} else if requeued > 0 {
+		log.Warn().Msgf("Requeued %v stale jobs", requeued)
+	}
+}
+
+// dispatch claims as many due jobs as there are free workers, within the
+// concurrency limit of each type.
+func (m *Manager) dispatch(ctx context.Context) {
+	free, full := m.capacity()
+	if free <= 0 {
+		return
+	}
+	jobs, err := m.JobDao.Due(ctx, free, full)
+	if err != nil {
+		log.Error().Err(err).Msg("Poll jobs failed")
+		return
+	}
+	for i := range jobs {
+		job := jobs[i]
+		if !m.available(job.Type) {
+			continue
+		}
+		claimed, err := m.JobDao.Claim(ctx, &job, m.owner)
+		if err != nil {
+			log.Error().Err(err).Msgf("Claim job %v failed", job.Id)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		jobCtx, cancel := context.WithCancel(ctx)
+		m.mu.Lock()
+		m.running[job.Id] = runningJob{jobType: job.Type, cancel: cancel}
+		m.mu.Unlock()
+		m.wg.Add(1)
+		go m.run(jobCtx, ctx, &job)
+	}
+}
+
+// capacity returns the number of free workers and the types at their limit.
+func (m *Manager) capacity() (int, []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]int)
+	for _, r := range m.running {
+		counts[r.jobType]++
+	}
+	var full []string
+	for jobType, limit := range m.Options.Concurrency {
+		if counts[jobType] >= limit {
+			full = append(full, jobType)
+		}
+	}
+	return m.Options.Workers - len(m.running), full
+}
+
+func (m *Manager) available(jobType string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.running) >= m.Options.Workers {
+		return false
+	}
+	limit, ok := m.Options.Concurrency[jobType]
+	if !ok {
+		return true
+	}
+	count := 0
+	for _, r := range m.running {
+		if r.jobType == jobType {
+			count++
+		}
+	}
+	return count < limit
+}
+
+func (m *Manager) run(ctx context.Context, managerCtx context.Context, job *model.Job) {
+	defer m.wg.Done()
+	defer func() {
+		m.mu.Lock()
+		m.running[job.Id].cancel()
+		delete(m.running, job.Id)
+		m.mu.Unlock()
+	}()
+
+	logger := log.With().Uint64("job_id", job.Id).Str("job_type", job.Type).Logger()
+	ctx = logger.WithContext(ctx)
+	err := m.handle(ctx, job)
+	status, runAt, lastError := constant.JobStatusDone, job.RunAt, ""
+	switch {
+	case err == nil:
+	case managerCtx.Err() != nil:
+		// shutting down, run it again on the next start
+		status, runAt, lastError = constant.JobStatusPending, time.Now(), err.Error()
+	case job.Attempts < job.MaxAttempts:
+		status, runAt, lastError = constant.JobStatusPending, time.Now().Add(m.backoff(job.Attempts)), err.Error()
+	default:
+		status, lastError = constant.JobStatusFailed, err.Error()
+	}
+	released, dbErr := m.JobDao.Release(context.Background(), job.Id, m.owner, status, runAt, lastError)
+	if dbErr != nil {
+		logger.Error().Err(dbErr).Msgf("Update job %v failed", job.Id)
+		return
+	}
+	if !released {
+		logger.Warn().Msgf("Job %v was cancelled or taken over while running", job.Id)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msgf("Job %v (%v) attempt %v/%v failed", job.Id, job.Type, job.Attempts, job.MaxAttempts)
+	}
+}
+
+func (m *Manager) handle(ctx context.Context, job *model.Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	h, ok := m.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler for job type %v", job.Type)
+	}
+	return h.Handle(ctx, []byte(job.Payload))
+}
+
+// backoff doubles the delay after each attempt, with up to 20% of jitter so failed
+// jobs don't retry all at once.
+func (m *Manager) backoff(attempt int) time.Duration {
+	d := m.Options.BackoffBase
+	for i := 1; i < attempt && d < m.Options.BackoffMax; i++ {
+		d *= 2
+	}
+	if d > m.Options.BackoffMax {
+		d = m.Options.BackoffMax
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+// elect keeps trying to take or renew the scheduler lock.
+func (m *Manager) elect(ctx context.Context) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.Options.LeaseTimeout / 3)
+	defer ticker.Stop()
+	for {
+		leader, err := m.JobDao.AcquireLock(ctx, constant.JobSchedulerLockName, m.owner, m.Options.LeaseTimeout)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Acquire job scheduler lock failed")
+		}
+		var value int32
+		if leader {
+			value = 1
+		}
+		if previous := atomic.SwapInt32(&m.leader, value); previous != value {
+			log.Info().Msgf("Job scheduler leader: %v", leader)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) fire(ctx context.Context, name string, s config.JobSchedule) {
+	if atomic.LoadInt32(&m.leader) == 0 {
+		return
+	}
+	var payload interface{}
+	if s.Payload != "" {
+		payload = json.RawMessage(s.Payload)
+	}
+	if _, err := m.Enqueue(ctx, s.Type, payload); err != nil {
+		log.Error().Err(err).Msgf("Enqueue scheduled job %v failed", name)
+	}
+}
+
+func newOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%v-%v-%x", host, os.Getpid(), rand.Uint32())
+}
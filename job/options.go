@@ -0,0 +1,33 @@
+package job
+
+import (
+	"demo-curd/config"
+	"time"
+)
+
+// Options is the job section of the config.
+type Options struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	LeaseTimeout time.Duration
+	Retention    time.Duration
+	Concurrency  map[string]int
+	Schedules    map[string]config.JobSchedule
+}
+
+func OptionsOf(c config.Config) Options {
+	return Options{
+		Workers:      c.Job.Workers,
+		PollInterval: c.Job.PollInterval,
+		MaxAttempts:  c.Job.MaxAttempts,
+		BackoffBase:  c.Job.BackoffBase,
+		BackoffMax:   c.Job.BackoffMax,
+		LeaseTimeout: c.Job.LeaseTimeout,
+		Retention:    c.Job.Retention,
+		Concurrency:  c.Job.Concurrency,
+		Schedules:    c.Job.Schedules,
+	}
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"time"
+)
+
+// GormLogger writes the logs of GORM through the logger of the context, so the sql
+// queries of a request carry its request id. Queries are logged at debug level, the
+// slow ones as warnings and the failed ones as errors.
+type GormLogger struct {
+	SlowThreshold time.Duration
+	level         gormlogger.LogLevel
+}
+
+func NewGormLogger(slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{
+		SlowThreshold: slowThreshold,
+		level:         gormlogger.Info,
+	}
+}
+
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		zerolog.Ctx(ctx).Info().Msgf(msg, data...)
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		zerolog.Ctx(ctx).Warn().Msgf(msg, data...)
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		zerolog.Ctx(ctx).Error().Msgf(msg, data...)
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	logger := zerolog.Ctx(ctx)
+	elapsed := time.Since(begin)
+	var event *zerolog.Event
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		event = logger.Error().Err(err)
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold && l.level >= gormlogger.Warn:
+		event = logger.Warn().Dur("slow_threshold", l.SlowThreshold)
+	case l.level >= gormlogger.Info:
+		event = logger.Debug()
+	default:
+		return
+	}
+	// the query is only built when the event is logged
+	if !event.Enabled() {
+		return
+	}
+	sql, rows := fc()
+	event.Dur("elapsed", elapsed).Int64("rows", rows).Msg(sql)
+}
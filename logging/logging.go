@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"demo-curd/config"
+	"demo-curd/util/constant"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	stdlog "log"
+	"os"
+	"time"
+)
+
+// Setup configures the global zerolog logger from the log section of c, the logs of
+// the standard library logger go through it as well. The loggers bound to a context
+// by the request id middleware or the job manager derive from it, log.Ctx falls back
+// to it outside of a request.
+func Setup(c config.Config) error {
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	logger := zerolog.New(os.Stdout)
+	if c.Log.Format == constant.LogFormatConsole {
+		logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "15:04:05.000"})
+	}
+	log.Logger = logger.With().Timestamp().Logger()
+	zerolog.DefaultContextLogger = &log.Logger
+
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(log.Logger)
+	return ApplyLevel(c)
+}
+
+// ApplyLevel sets the level of all the loggers to log.level, it's reloadable.
+func ApplyLevel(c config.Config) error {
+	level, err := zerolog.ParseLevel(c.Log.Level)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(level)
+	return nil
+}
@@ -7,9 +7,15 @@ import (
 	"demo-curd/database"
 	"demo-curd/docs"
 	"demo-curd/i18n"
+	"demo-curd/job"
+	"demo-curd/logging"
+	"demo-curd/migration"
 	"demo-curd/model"
 	"demo-curd/router"
+	"demo-curd/tracing"
 	"demo-curd/util"
+	"demo-curd/util/constant"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"net/http"
@@ -20,11 +26,21 @@ import (
 )
 
 type App struct {
-	Config    config.Config
-	Database  *database.Database
-	Router    *router.Router
-	I18n      *i18n.I18n
-	CurdV1Api *v1.CurdV1Api
+	Config        config.Config
+	ConfigWatcher *config.Watcher
+	Tracing       *tracing.Tracing
+	Database      *database.Database
+	Datasources   database.Datasources
+	Router        *router.Router
+	I18n          *i18n.I18n
+	Migrator      *migration.Migrator
+	JobManager    *job.Manager
+	Idempotency   *router.IdempotencyMiddleware
+	RateLimiter   *router.RateLimiter
+	CurdV1Api     *v1.CurdV1Api
+	JobV1Api      *v1.JobV1Api
+	WebhookV1Api  *v1.WebhookV1Api
+	I18nV1Api     *v1.I18nV1Api
 }
 
 func (r App) Start() error {
@@ -32,7 +48,25 @@ func (r App) Start() error {
 	r.SetupRouters()
 
 	// migration
-	r.Database.DB.AutoMigrate(&model.Curd{})
+	if err := r.Migrate(); err != nil {
+		return err
+	}
+
+	// background jobs
+	r.JobManager.Start()
+
+	// hot reload of log.level, cors, security, rateLimit and the messages of i18n.dir
+	reloadables := []config.Reloadable{config.ReloadFunc(logging.ApplyLevel), r.Router, r.RateLimiter, r.I18n}
+	var dirs []string
+	if r.Config.I18n.Dir != "" {
+		dirs = append(dirs, r.Config.I18n.Dir)
+	}
+	if err := r.ConfigWatcher.Watch(reloadables, dirs...); err != nil {
+		return err
+	}
+
+	// messages edited by the admins, refreshed from the database
+	r.I18n.Start()
 
 	// run Gin engine
 	util.CheckError(r.Router.Engine.Run(fmt.Sprintf(":%s", r.Config.Server.Port)))
@@ -46,25 +80,88 @@ func (r App) Start() error {
 }
 
 func (r App) Stop() {
+	if err := r.ConfigWatcher.Close(); err != nil {
+		log.Error().Err(err).Msg("Can't stop the config watcher")
+	}
+	r.I18n.Stop()
+	r.JobManager.Stop()
 	if err := r.Database.Close(); err != nil {
 		panic(err)
 	}
+	if err := r.Datasources.Close(); err != nil {
+		panic(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), constant.TracingShutdownTimeout)
+	defer cancel()
+	if err := r.Tracing.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Can't export the pending spans")
+	}
+}
+
+// Migrate applies pending versioned migrations. GORM AutoMigrate is only allowed in
+// development because it can't drop or rename columns and isn't versioned.
+func (r App) Migrate() error {
+	if r.Config.Database.AutoMigrate {
+		if !r.Config.IsDev() {
+			return errors.New("database.autoMigrate is only allowed in development environments")
+		}
+		return r.Database.DB.AutoMigrate(&model.Curd{}, &model.Job{}, &model.JobLock{},
+			&model.WebhookSubscription{}, &model.WebhookDelivery{}, &model.IdempotencyKey{}, &model.Translation{}, &model.CurdImport{})
+	}
+	if !r.Config.Database.MigrateOnStart {
+		return nil
+	}
+	return r.Migrator.Up(context.Background())
 }
 
 func (r App) SetupRouters() {
 	// test group
 	// public api v1
 	groupPublicV1 := r.Router.Engine.Group("/api/public/v1")
+	groupPublicV1.Use(r.RateLimiter.MiddlewareFunc(), r.Idempotency.MiddlewareFunc())
 	{
 		// foo API
 		groupPublicV1.POST("c", r.CurdV1Api.Create)
 	}
 	// authorized api v1
 	groupV1 := r.Router.Engine.Group("/api/v1")
-	groupV1.Use(r.Router.AuthMiddleware.MiddlewareFunc())
+	groupV1.Use(r.Router.AuthMiddleware.MiddlewareFunc(), router.TenantMiddleware(),
+		r.RateLimiter.MiddlewareFunc(), r.Idempotency.MiddlewareFunc())
 	{
 		// foo API
+		groupV1.GET("curd", r.CurdV1Api.List)
 		groupV1.POST("curd", r.CurdV1Api.Create)
+		groupV1.GET("curd/export", r.CurdV1Api.Export)
+		groupV1.GET("curd/search", r.CurdV1Api.Search)
+		groupV1.GET("curd/:id", r.CurdV1Api.Get)
+		groupV1.PATCH("curd/:id", r.CurdV1Api.Patch)
+		groupV1.POST("curd/bulk", r.CurdV1Api.BulkCreate)
+		groupV1.PUT("curd/bulk", r.CurdV1Api.BulkUpdate)
+		groupV1.DELETE("curd/bulk", r.CurdV1Api.BulkDelete)
+		groupV1.POST("curd/import", r.CurdV1Api.Import)
+		groupV1.GET("curd/import/:id", r.CurdV1Api.GetImport)
+		groupV1.GET("curd/import/:id/report", r.CurdV1Api.GetImportReport)
+
+		groupV1.POST("webhooks", r.WebhookV1Api.Create)
+		groupV1.GET("webhooks", r.WebhookV1Api.List)
+		groupV1.GET("webhooks/:id", r.WebhookV1Api.Get)
+		groupV1.PUT("webhooks/:id", r.WebhookV1Api.Update)
+		groupV1.DELETE("webhooks/:id", r.WebhookV1Api.Delete)
+		groupV1.GET("webhooks/:id/deliveries", r.WebhookV1Api.Deliveries)
+
+		// admin API
+		groupV1.GET("admin/jobs", r.JobV1Api.List)
+		groupV1.GET("admin/jobs/:id", r.JobV1Api.Get)
+		groupV1.POST("admin/jobs/:id/retry", r.JobV1Api.Retry)
+		groupV1.POST("admin/jobs/:id/cancel", r.JobV1Api.Cancel)
+		groupV1.GET("admin/i18n/missing", r.I18nV1Api.Missing)
+		groupV1.GET("admin/i18n/messages", r.I18nV1Api.List)
+		groupV1.POST("admin/i18n/messages", r.I18nV1Api.Create)
+		groupV1.GET("admin/i18n/messages/:id", r.I18nV1Api.Get)
+		groupV1.PUT("admin/i18n/messages/:id", r.I18nV1Api.Update)
+		groupV1.DELETE("admin/i18n/messages/:id", r.I18nV1Api.Delete)
+		groupV1.GET("admin/i18n/export/:lang", r.I18nV1Api.Export)
+		groupV1.POST("admin/i18n/import/:lang", r.I18nV1Api.Import)
 	}
 
 	// init swagger
@@ -73,7 +170,7 @@ func (r App) SetupRouters() {
 
 func (r App) InitSwagger(c config.Config) {
 	docs.SwaggerInfo.Host = c.Swagger.Url
-	fmt.Println("docs.SwaggerInfo.Host....", docs.SwaggerInfo.Host)
+	log.Debug().Msgf("Swagger host: %v", docs.SwaggerInfo.Host)
 	r.Router.InitSwagger(c)
 }
 
@@ -91,9 +188,22 @@ func (r App) InitSwagger(c config.Config) {
 // @in header
 // @name Authorization
 func main() {
-	// wire
+	// sub commands, e.g. migrate up
+	if len(os.Args) > 1 {
+		util.CheckError(runCommand(os.Args[1:]))
+		return
+	}
+
+	// wire, an invalid config stops here with the report of all its problems
 	app, err := InitApp()
-	util.CheckError(err)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err = logging.Setup(app.Config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	err = app.Start()
 	util.CheckError(err)
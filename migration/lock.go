@@ -0,0 +1,99 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"demo-curd/util/constant"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+const lockTimeout = 5 * time.Minute
+const lockRetryInterval = time.Second
+
+var ErrLockTimeout = errors.New("timeout waiting for migration lock, another instance is migrating")
+
+// advisoryLock is a named database lock held by a dedicated connection, so only one
+// replica runs migrations at a time. The lock is released when the connection closes.
+type advisoryLock struct {
+	conn    *sql.Conn
+	release func(ctx context.Context, conn *sql.Conn) error
+}
+
+func acquireLock(ctx context.Context, sqlDB *sql.DB, dialect string, name string) (*advisoryLock, error) {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lock := &advisoryLock{conn: conn}
+	switch dialect {
+	case constant.DriverMySQL:
+		err = mysqlLock(ctx, conn, name)
+		lock.release = func(ctx context.Context, conn *sql.Conn) error {
+			var released sql.NullInt64
+			return conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", name).Scan(&released)
+		}
+	case constant.DriverPostgres:
+		key := lockKey(name)
+		err = postgresLock(ctx, conn, key)
+		lock.release = func(ctx context.Context, conn *sql.Conn) error {
+			var released bool
+			return conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", key).Scan(&released)
+		}
+	default:
+		// sqlite is an embedded single file database, writes are already serialized
+		lock.release = func(ctx context.Context, conn *sql.Conn) error { return nil }
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return lock, nil
+}
+
+func (l *advisoryLock) Release(ctx context.Context) error {
+	defer l.conn.Close()
+	return l.release(ctx, l.conn)
+}
+
+func mysqlLock(ctx context.Context, conn *sql.Conn, name string) error {
+	var acquired sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, int(lockTimeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return ErrLockTimeout
+	}
+	return nil
+}
+
+func postgresLock(ctx context.Context, conn *sql.Conn, key int64) error {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if acquired {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrLockTimeout
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// lockKey maps the lock name to the bigint key used by postgres advisory locks.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
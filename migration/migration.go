@@ -0,0 +1,290 @@
+package migration
+
+import (
+	"context"
+	"demo-curd/database"
+	"demo-curd/util/constant"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrDirty            = errors.New("failed midway, the schema is partly applied")
+)
+
+// dialects whose DDL statements commit implicitly, a migration can't be rolled back
+// there when one of its statements fails
+var nonTransactionalDDL = map[string]bool{"mysql": true}
+
+type Migrator struct {
+	Db         *database.Database
+	Dialect    string
+	Migrations []Migration
+}
+
+type SchemaMigration struct {
+	Version   uint64 `gorm:"primarykey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	Checksum  string `gorm:"size:64"`
+	AppliedAt time.Time
+	// set while a migration runs without a transaction, left set when it fails
+	Dirty bool `gorm:"not null;default:false"`
+}
+
+func (SchemaMigration) TableName() string {
+	return constant.MigrationTable
+}
+
+type Status struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// the file changed after the migration was applied
+	Dirty bool
+	// the migration failed midway, see Migrator.Force
+	Failed bool
+}
+
+func NewMigrator(db *database.Database) (*Migrator, error) {
+	dialect := db.DB.Dialector.Name()
+	sqlFS, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations(sqlFS, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		Db:         db,
+		Dialect:    dialect,
+		Migrations: migrations,
+	}, nil
+}
+
+// Up applies all pending migrations in version order.
+func (r *Migrator) Up(ctx context.Context) error {
+	return r.withLock(ctx, func(applied map[uint64]SchemaMigration) error {
+		for _, m := range r.Migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			log.Info().Msgf("Applying migration %v_%v", m.Version, m.Name)
+			row := SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				Checksum:  m.Checksum,
+				AppliedAt: time.Now(),
+			}
+			if err := r.apply(ctx, m.Up, func(tx *gorm.DB, dirty bool) error {
+				row.Dirty = dirty
+				return tx.Save(&row).Error
+			}); err != nil {
+				return fmt.Errorf("migration %v_%v: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the last n applied migrations.
+func (r *Migrator) Down(ctx context.Context, n int) error {
+	return r.withLock(ctx, func(applied map[uint64]SchemaMigration) error {
+		for i := len(r.Migrations) - 1; i >= 0 && n > 0; i-- {
+			m := r.Migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %v_%v has no down file", m.Version, m.Name)
+			}
+			log.Info().Msgf("Rolling back migration %v_%v", m.Version, m.Name)
+			row := applied[m.Version]
+			if err := r.apply(ctx, m.Down, func(tx *gorm.DB, dirty bool) error {
+				if dirty {
+					row.Dirty = true
+					return tx.Save(&row).Error
+				}
+				return tx.Delete(&SchemaMigration{Version: m.Version}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %v_%v: %w", m.Version, m.Name, err)
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// Status returns all known migrations with their applied state. Migrations whose
+// file changed after they were applied are reported as dirty.
+func (r *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(r.Migrations))
+	for _, m := range r.Migrations {
+		s := Status{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			appliedAt := a.AppliedAt
+			s.Applied = true
+			s.AppliedAt = &appliedAt
+			s.Dirty = a.Checksum != m.Checksum
+			s.Failed = a.Dirty
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Force marks a migration that failed midway as applied, once its schema changes were
+// completed by hand.
+func (r *Migrator) Force(ctx context.Context, version uint64) error {
+	return r.lock(ctx, true, func(applied map[uint64]SchemaMigration) error {
+		row, ok := applied[version]
+		if !ok || !row.Dirty {
+			return fmt.Errorf("migration %v didn't fail", version)
+		}
+		return r.Db.Primary().WithContext(ctx).Model(&row).Update("dirty", false).Error
+	})
+}
+
+// Create writes an empty up/down migration pair for every dialect into dir and
+// returns the created paths, so no dialect is forgotten.
+func Create(dir string, name string) ([]string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	version := time.Now().UTC().Format("20060102150405")
+	fileName := fmt.Sprintf("%v_%v", version, name)
+	if !fileNamePattern.MatchString(fileName + ".up.sql") {
+		return nil, fmt.Errorf("invalid migration name: %v", name)
+	}
+	var paths []string
+	for _, dialect := range dialects {
+		if err := os.MkdirAll(filepath.Join(dir, dialect), 0755); err != nil {
+			return nil, err
+		}
+		base := filepath.Join(dir, dialect, fileName)
+		up, down := base+".up.sql", base+".down.sql"
+		if err := os.WriteFile(up, []byte("-- write your up migration here\n"), 0644); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(down, []byte("-- write your down migration here\n"), 0644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, up, down)
+	}
+	return paths, nil
+}
+
+func (r *Migrator) withLock(ctx context.Context, fn func(applied map[uint64]SchemaMigration) error) (err error) {
+	return r.lock(ctx, false, fn)
+}
+
+// lock runs fn holding the migration lock, force runs it even when a migration failed
+// midway.
+func (r *Migrator) lock(ctx context.Context, force bool, fn func(applied map[uint64]SchemaMigration) error) (err error) {
+	sqlDB, err := r.Db.DB.DB()
+	if err != nil {
+		return err
+	}
+	lock, err := acquireLock(ctx, sqlDB, r.Dialect, constant.MigrationLockName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errRelease := lock.Release(ctx); errRelease != nil && err == nil {
+			err = errRelease
+		}
+	}()
+
+	if err = r.ensureTable(ctx); err != nil {
+		return err
+	}
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err = r.verify(applied); err != nil && !(errors.Is(err, ErrDirty) && force) {
+		return err
+	}
+	return fn(applied)
+}
+
+func (r *Migrator) ensureTable(ctx context.Context) error {
+	// also adds the columns missing in the tables of older versions
+	return r.Db.Primary().WithContext(ctx).AutoMigrate(&SchemaMigration{})
+}
+
+func (r *Migrator) applied(ctx context.Context) (map[uint64]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := r.Db.Primary().WithContext(ctx).Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// verify makes sure applied migrations were not edited or removed afterwards.
+func (r *Migrator) verify(applied map[uint64]SchemaMigration) error {
+	known := make(map[uint64]Migration, len(r.Migrations))
+	for _, m := range r.Migrations {
+		known[m.Version] = m
+	}
+	for version, a := range applied {
+		m, ok := known[version]
+		if !ok {
+			return fmt.Errorf("applied migration %v_%v is missing from the source", a.Version, a.Name)
+		}
+		if m.Checksum != a.Checksum {
+			return fmt.Errorf("migration %v_%v: %w", m.Version, m.Name, ErrChecksumMismatch)
+		}
+	}
+	for _, a := range applied {
+		if a.Dirty {
+			return fmt.Errorf("migration %v_%v %w, complete it by hand then run migrate force %v", a.Version, a.Name, ErrDirty, a.Version)
+		}
+	}
+	return nil
+}
+
+// apply runs script then record in a transaction. Where DDL commits implicitly, record
+// is first called with dirty true, so a failing statement leaves the version dirty
+// instead of looking either applied or pending.
+func (r *Migrator) apply(ctx context.Context, script string, record func(tx *gorm.DB, dirty bool) error) error {
+	db := r.Db.Primary().WithContext(ctx)
+	if nonTransactionalDDL[r.Dialect] {
+		if err := record(db, true); err != nil {
+			return err
+		}
+		for _, stmt := range splitStatements(script) {
+			if err := db.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return record(db, false)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range splitStatements(script) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx, false)
+	})
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS curd
+(
+    id         BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(255),
+    email      VARCHAR(255),
+    phone      VARCHAR(20),
+    city       VARCHAR(255),
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    deleted_at DATETIME(3) NULL,
+    INDEX idx_curd_deleted_at (deleted_at)
+);
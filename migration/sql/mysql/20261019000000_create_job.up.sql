@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS job
+(
+    id           BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    type         VARCHAR(100) NOT NULL,
+    payload      TEXT,
+    status       VARCHAR(20)  NOT NULL,
+    attempts     INT          NOT NULL DEFAULT 0,
+    max_attempts INT          NOT NULL DEFAULT 1,
+    run_at       DATETIME(3)  NOT NULL,
+    locked_by    VARCHAR(100),
+    locked_at    DATETIME(3) NULL,
+    last_error   TEXT,
+    finished_at  DATETIME(3) NULL,
+    created_at   DATETIME(3) NULL,
+    updated_at   DATETIME(3) NULL,
+    INDEX idx_job_status_run_at (status, run_at),
+    INDEX idx_job_type (type)
+);
+
+CREATE TABLE IF NOT EXISTS job_lock
+(
+    name       VARCHAR(100) PRIMARY KEY,
+    owner      VARCHAR(100) NOT NULL,
+    expires_at DATETIME(3)  NOT NULL
+);
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS webhook_subscription
+(
+    id            BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    tenant_id     VARCHAR(100) NOT NULL DEFAULT '',
+    url           VARCHAR(2048) NOT NULL,
+    secret        VARCHAR(255)  NOT NULL,
+    event_types   VARCHAR(255)  NOT NULL,
+    active        TINYINT(1)    NOT NULL DEFAULT 1,
+    failure_count INT           NOT NULL DEFAULT 0,
+    disabled_at   DATETIME(3) NULL,
+    created_at    DATETIME(3) NULL,
+    updated_at    DATETIME(3) NULL,
+    INDEX idx_webhook_subscription_tenant_id (tenant_id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery
+(
+    id              BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    subscription_id BIGINT UNSIGNED NOT NULL,
+    event_id        VARCHAR(64)  NOT NULL,
+    event_type      VARCHAR(100) NOT NULL,
+    attempt         INT          NOT NULL,
+    status_code     INT          NOT NULL DEFAULT 0,
+    success         TINYINT(1)   NOT NULL DEFAULT 0,
+    error           TEXT,
+    duration_ms     BIGINT       NOT NULL DEFAULT 0,
+    created_at      DATETIME(3) NULL,
+    INDEX idx_webhook_delivery_subscription_id (subscription_id, created_at),
+    INDEX idx_webhook_delivery_event_id (event_id)
+);
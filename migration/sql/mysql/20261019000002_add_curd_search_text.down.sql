@@ -0,0 +1,2 @@
+ALTER TABLE curd DROP INDEX ft_curd_search_text;
+ALTER TABLE curd DROP COLUMN search_text;
@@ -0,0 +1,4 @@
+ALTER TABLE curd ADD COLUMN search_text TEXT;
+-- the ngram parser indexes every 2 characters sequence, so fragments inside a word
+-- or a phone number match too
+ALTER TABLE curd ADD FULLTEXT INDEX ft_curd_search_text (search_text) WITH PARSER ngram;
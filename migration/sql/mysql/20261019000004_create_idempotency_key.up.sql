@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS idempotency_key
+(
+    id              BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    scope           VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash    VARCHAR(64)  NOT NULL,
+    completed       TINYINT(1)   NOT NULL DEFAULT 0,
+    status_code     INT          NOT NULL DEFAULT 0,
+    content_type    VARCHAR(255) NOT NULL DEFAULT '',
+    response        MEDIUMTEXT,
+    expires_at      DATETIME(3)  NOT NULL,
+    created_at      DATETIME(3) NULL,
+    updated_at      DATETIME(3) NULL,
+    UNIQUE INDEX uk_idempotency_key_scope_key (scope, idempotency_key),
+    INDEX idx_idempotency_key_expires_at (expires_at)
+);
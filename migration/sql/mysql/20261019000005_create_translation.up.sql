@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS translation
+(
+    id         BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    lang       VARCHAR(35)  NOT NULL,
+    message_id VARCHAR(255) NOT NULL,
+    one        TEXT,
+    other      TEXT         NOT NULL,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    UNIQUE INDEX uk_translation_lang_message_id (lang, message_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE curd DROP INDEX uk_curd_tenant_id_email, DROP COLUMN email_key;
@@ -0,0 +1,5 @@
+-- MySQL has no partial index, the generated column is NULL for the soft deleted rows
+-- and the empty emails so they never conflict
+ALTER TABLE curd
+    ADD COLUMN email_key VARCHAR(255) AS (CASE WHEN deleted_at IS NULL AND email <> '' THEN LOWER(email) END) VIRTUAL,
+    ADD UNIQUE INDEX uk_curd_tenant_id_email (tenant_id, email_key);
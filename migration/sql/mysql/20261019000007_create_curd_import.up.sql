@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS curd_import
+(
+    id            VARCHAR(32)   NOT NULL PRIMARY KEY,
+    tenant_id     VARCHAR(100)  NOT NULL DEFAULT '',
+    created_by    VARCHAR(100)  NOT NULL DEFAULT '',
+    file_name     VARCHAR(255)  NOT NULL,
+    file_path     VARCHAR(1024) NOT NULL,
+    report_path   VARCHAR(1024) NOT NULL DEFAULT '',
+    dry_run       TINYINT(1)    NOT NULL DEFAULT 0,
+    status        VARCHAR(20)   NOT NULL,
+    total_rows    INT           NOT NULL DEFAULT 0,
+    imported_rows INT           NOT NULL DEFAULT 0,
+    rejected_rows INT           NOT NULL DEFAULT 0,
+    has_report    TINYINT(1)    NOT NULL DEFAULT 0,
+    error_message TEXT,
+    finished_at   DATETIME(3) NULL,
+    created_at    DATETIME(3) NULL,
+    updated_at    DATETIME(3) NULL,
+    INDEX idx_curd_import_finished_at (finished_at)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS curd
+(
+    id         BIGSERIAL PRIMARY KEY,
+    name       VARCHAR(255),
+    email      VARCHAR(255),
+    phone      VARCHAR(20),
+    city       VARCHAR(255),
+    created_at TIMESTAMPTZ NULL,
+    updated_at TIMESTAMPTZ NULL,
+    deleted_at TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_curd_deleted_at ON curd (deleted_at);
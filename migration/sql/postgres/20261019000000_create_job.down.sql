@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS job_lock;
+DROP TABLE IF EXISTS job;
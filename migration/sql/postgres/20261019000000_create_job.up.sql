@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS job
+(
+    id           BIGSERIAL PRIMARY KEY,
+    type         VARCHAR(100) NOT NULL,
+    payload      TEXT,
+    status       VARCHAR(20)  NOT NULL,
+    attempts     INTEGER      NOT NULL DEFAULT 0,
+    max_attempts INTEGER      NOT NULL DEFAULT 1,
+    run_at       TIMESTAMPTZ  NOT NULL,
+    locked_by    VARCHAR(100),
+    locked_at    TIMESTAMPTZ NULL,
+    last_error   TEXT,
+    finished_at  TIMESTAMPTZ NULL,
+    created_at   TIMESTAMPTZ NULL,
+    updated_at   TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_job_status_run_at ON job (status, run_at);
+CREATE INDEX IF NOT EXISTS idx_job_type ON job (type);
+
+CREATE TABLE IF NOT EXISTS job_lock
+(
+    name       VARCHAR(100) PRIMARY KEY,
+    owner      VARCHAR(100) NOT NULL,
+    expires_at TIMESTAMPTZ  NOT NULL
+);
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS webhook_subscription
+(
+    id            BIGSERIAL PRIMARY KEY,
+    tenant_id     VARCHAR(100)  NOT NULL DEFAULT '',
+    url           VARCHAR(2048) NOT NULL,
+    secret        VARCHAR(255)  NOT NULL,
+    event_types   VARCHAR(255)  NOT NULL,
+    active        BOOLEAN       NOT NULL DEFAULT TRUE,
+    failure_count INTEGER       NOT NULL DEFAULT 0,
+    disabled_at   TIMESTAMPTZ NULL,
+    created_at    TIMESTAMPTZ NULL,
+    updated_at    TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_subscription_tenant_id ON webhook_subscription (tenant_id);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery
+(
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT       NOT NULL,
+    event_id        VARCHAR(64)  NOT NULL,
+    event_type      VARCHAR(100) NOT NULL,
+    attempt         INTEGER      NOT NULL,
+    status_code     INTEGER      NOT NULL DEFAULT 0,
+    success         BOOLEAN      NOT NULL DEFAULT FALSE,
+    error           TEXT,
+    duration_ms     BIGINT       NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_subscription_id ON webhook_delivery (subscription_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_event_id ON webhook_delivery (event_id);
@@ -0,0 +1 @@
+ALTER TABLE curd DROP COLUMN search_text;
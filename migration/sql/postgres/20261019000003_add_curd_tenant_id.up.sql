@@ -0,0 +1,4 @@
+ALTER TABLE curd ADD COLUMN tenant_id VARCHAR(100) NOT NULL DEFAULT '';
+-- emails are unique per tenant, checked by the application since soft deleted rows
+-- keep their email
+CREATE INDEX idx_curd_tenant_id_email ON curd (tenant_id, email);
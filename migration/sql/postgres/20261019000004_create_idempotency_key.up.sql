@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS idempotency_key
+(
+    id              BIGSERIAL PRIMARY KEY,
+    scope           VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash    VARCHAR(64)  NOT NULL,
+    completed       BOOLEAN      NOT NULL DEFAULT FALSE,
+    status_code     INTEGER      NOT NULL DEFAULT 0,
+    content_type    VARCHAR(255) NOT NULL DEFAULT '',
+    response        TEXT,
+    expires_at      TIMESTAMPTZ  NOT NULL,
+    created_at      TIMESTAMPTZ NULL,
+    updated_at      TIMESTAMPTZ NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_idempotency_key_scope_key ON idempotency_key (scope, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_key_expires_at ON idempotency_key (expires_at);
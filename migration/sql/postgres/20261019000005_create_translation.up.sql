@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS translation
+(
+    id         BIGSERIAL PRIMARY KEY,
+    lang       VARCHAR(35)  NOT NULL,
+    message_id VARCHAR(255) NOT NULL,
+    one        TEXT,
+    other      TEXT         NOT NULL,
+    created_at TIMESTAMPTZ NULL,
+    updated_at TIMESTAMPTZ NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_translation_lang_message_id ON translation (lang, message_id);
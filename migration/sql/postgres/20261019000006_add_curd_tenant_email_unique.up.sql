@@ -0,0 +1,3 @@
+-- soft deleted rows keep their email, only the live ones are unique per tenant
+CREATE UNIQUE INDEX uk_curd_tenant_id_email ON curd (tenant_id, LOWER(email))
+    WHERE deleted_at IS NULL AND email <> '';
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS curd_import
+(
+    id            VARCHAR(32)   PRIMARY KEY,
+    tenant_id     VARCHAR(100)  NOT NULL DEFAULT '',
+    created_by    VARCHAR(100)  NOT NULL DEFAULT '',
+    file_name     VARCHAR(255)  NOT NULL,
+    file_path     VARCHAR(1024) NOT NULL,
+    report_path   VARCHAR(1024) NOT NULL DEFAULT '',
+    dry_run       BOOLEAN       NOT NULL DEFAULT FALSE,
+    status        VARCHAR(20)   NOT NULL,
+    total_rows    INTEGER       NOT NULL DEFAULT 0,
+    imported_rows INTEGER       NOT NULL DEFAULT 0,
+    rejected_rows INTEGER       NOT NULL DEFAULT 0,
+    has_report    BOOLEAN       NOT NULL DEFAULT FALSE,
+    error_message TEXT,
+    finished_at   TIMESTAMPTZ NULL,
+    created_at    TIMESTAMPTZ NULL,
+    updated_at    TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_curd_import_finished_at ON curd_import (finished_at);
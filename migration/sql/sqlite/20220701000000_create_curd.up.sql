@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS curd
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       TEXT,
+    email      TEXT,
+    phone      TEXT,
+    city       TEXT,
+    created_at DATETIME NULL,
+    updated_at DATETIME NULL,
+    deleted_at DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_curd_deleted_at ON curd (deleted_at);
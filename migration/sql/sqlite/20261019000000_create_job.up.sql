@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS job
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    type         TEXT     NOT NULL,
+    payload      TEXT,
+    status       TEXT     NOT NULL,
+    attempts     INTEGER  NOT NULL DEFAULT 0,
+    max_attempts INTEGER  NOT NULL DEFAULT 1,
+    run_at       DATETIME NOT NULL,
+    locked_by    TEXT,
+    locked_at    DATETIME NULL,
+    last_error   TEXT,
+    finished_at  DATETIME NULL,
+    created_at   DATETIME NULL,
+    updated_at   DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_job_status_run_at ON job (status, run_at);
+CREATE INDEX IF NOT EXISTS idx_job_type ON job (type);
+
+CREATE TABLE IF NOT EXISTS job_lock
+(
+    name       TEXT PRIMARY KEY,
+    owner      TEXT     NOT NULL,
+    expires_at DATETIME NOT NULL
+);
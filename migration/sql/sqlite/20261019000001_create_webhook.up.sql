@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS webhook_subscription
+(
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id     TEXT    NOT NULL DEFAULT '',
+    url           TEXT    NOT NULL,
+    secret        TEXT    NOT NULL,
+    event_types   TEXT    NOT NULL,
+    active        NUMERIC NOT NULL DEFAULT 1,
+    failure_count INTEGER NOT NULL DEFAULT 0,
+    disabled_at   DATETIME NULL,
+    created_at    DATETIME NULL,
+    updated_at    DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_subscription_tenant_id ON webhook_subscription (tenant_id);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id INTEGER NOT NULL,
+    event_id        TEXT    NOT NULL,
+    event_type      TEXT    NOT NULL,
+    attempt         INTEGER NOT NULL,
+    status_code     INTEGER NOT NULL DEFAULT 0,
+    success         NUMERIC NOT NULL DEFAULT 0,
+    error           TEXT,
+    duration_ms     INTEGER NOT NULL DEFAULT 0,
+    created_at      DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_subscription_id ON webhook_delivery (subscription_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_event_id ON webhook_delivery (event_id);
@@ -0,0 +1 @@
+ALTER TABLE curd ADD COLUMN search_text TEXT;
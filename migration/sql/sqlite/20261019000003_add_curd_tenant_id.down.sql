@@ -0,0 +1,2 @@
+DROP INDEX idx_curd_tenant_id_email;
+ALTER TABLE curd DROP COLUMN tenant_id;
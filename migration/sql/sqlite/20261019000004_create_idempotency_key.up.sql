@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS idempotency_key
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope           TEXT     NOT NULL,
+    idempotency_key TEXT     NOT NULL,
+    request_hash    TEXT     NOT NULL,
+    completed       NUMERIC  NOT NULL DEFAULT 0,
+    status_code     INTEGER  NOT NULL DEFAULT 0,
+    content_type    TEXT     NOT NULL DEFAULT '',
+    response        TEXT,
+    expires_at      DATETIME NOT NULL,
+    created_at      DATETIME NULL,
+    updated_at      DATETIME NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_idempotency_key_scope_key ON idempotency_key (scope, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_key_expires_at ON idempotency_key (expires_at);
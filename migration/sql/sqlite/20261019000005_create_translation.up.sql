@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS translation
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    lang       TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    one        TEXT,
+    other      TEXT NOT NULL,
+    created_at DATETIME NULL,
+    updated_at DATETIME NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_translation_lang_message_id ON translation (lang, message_id);
@@ -0,0 +1 @@
+DROP INDEX uk_curd_tenant_id_email;
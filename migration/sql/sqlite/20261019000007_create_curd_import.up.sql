@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS curd_import
+(
+    id            TEXT PRIMARY KEY,
+    tenant_id     TEXT    NOT NULL DEFAULT '',
+    created_by    TEXT    NOT NULL DEFAULT '',
+    file_name     TEXT    NOT NULL,
+    file_path     TEXT    NOT NULL,
+    report_path   TEXT    NOT NULL DEFAULT '',
+    dry_run       NUMERIC NOT NULL DEFAULT 0,
+    status        TEXT    NOT NULL,
+    total_rows    INTEGER NOT NULL DEFAULT 0,
+    imported_rows INTEGER NOT NULL DEFAULT 0,
+    rejected_rows INTEGER NOT NULL DEFAULT 0,
+    has_report    NUMERIC NOT NULL DEFAULT 0,
+    error_message TEXT,
+    finished_at   DATETIME NULL,
+    created_at    DATETIME NULL,
+    updated_at    DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_curd_import_finished_at ON curd_import (finished_at);
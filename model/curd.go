@@ -1,16 +1,33 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"demo-curd/util/textutil"
+	"gorm.io/gorm"
+	"strings"
+)
 
 type Curd struct {
-	Id    uint   `gorm:"primarykey"`
-	Name  string `gorm:"name"`
-	Email string `gorm:"email"`
-	Phone string `gorm:"phone"`
-	City  string `gorm:"city"`
+	Id       uint   `gorm:"primarykey"`
+	TenantId string `gorm:"tenant_id"`
+	Name     string `gorm:"name"`
+	Email    string `gorm:"email"`
+	Phone    string `gorm:"phone"`
+	City     string `gorm:"city"`
+	// normalized name, email, phone and city, see textutil.Normalize
+	SearchText string `gorm:"search_text"`
 	gorm.Model
 }
 
 func (Curd) TableName() string {
 	return "curd"
-}
\ No newline at end of file
+}
+
+// BeforeSave keeps the search text in sync with the searchable fields.
+func (c *Curd) BeforeSave(tx *gorm.DB) error {
+	c.SearchText = CurdSearchText(c.Name, c.Email, c.Phone, c.City)
+	return nil
+}
+
+func CurdSearchText(name string, email string, phone string, city string) string {
+	return textutil.Normalize(strings.Join([]string{name, email, phone, city}, " "))
+}
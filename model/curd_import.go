@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// CurdImport is the import of a csv or xlsx file run by a curd.import job, only the user
+// who uploaded the file can see it. FilePath and ReportPath are in import.dir.
+type CurdImport struct {
+	Id           string `gorm:"primarykey"`
+	TenantId     string
+	CreatedBy    string
+	FileName     string
+	FilePath     string
+	ReportPath   string
+	DryRun       bool
+	Status       string
+	TotalRows    int
+	ImportedRows int
+	RejectedRows int
+	HasReport    bool
+	ErrorMessage string
+	FinishedAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (CurdImport) TableName() string {
+	return "curd_import"
+}
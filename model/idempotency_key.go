@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// IdempotencyKey remembers the response of a request sent with an Idempotency-Key
+// header, so retries of the same request get the same response. A key without
+// response yet belongs to a request still in progress.
+type IdempotencyKey struct {
+	Id          uint64 `gorm:"primarykey"`
+	Scope       string
+	Key         string `gorm:"column:idempotency_key"`
+	RequestHash string
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Response    string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_key"
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// Job is a unit of background work stored in the job queue, Payload is the json
+// encoded argument of the handler registered for Type.
+type Job struct {
+	Id          uint64 `gorm:"primarykey"`
+	Type        string
+	Payload     string
+	Status      string
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LockedBy    string
+	LockedAt    *time.Time
+	LastError   string
+	FinishedAt  *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (Job) TableName() string {
+	return "job"
+}
+
+// JobLock is a lease held by a single instance, e.g. the scheduler leader.
+type JobLock struct {
+	Name      string `gorm:"primarykey"`
+	Owner     string
+	ExpiresAt time.Time
+}
+
+func (JobLock) TableName() string {
+	return "job_lock"
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Translation is a message edited by the admins, it replaces the message of the files
+// with the same id and language. One is the singular form of the text, if any.
+type Translation struct {
+	Id        uint64 `gorm:"primarykey"`
+	Lang      string
+	MessageId string
+	One       string
+	Other     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Translation) TableName() string {
+	return "translation"
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// WebhookSubscription receives the events of EventTypes, a comma separated list where
+// "*" matches every event. A subscription without tenant receives the events of all
+// tenants.
+type WebhookSubscription struct {
+	Id           uint64 `gorm:"primarykey"`
+	TenantId     string
+	Url          string
+	Secret       string
+	EventTypes   string
+	Active       bool
+	FailureCount int
+	DisabledAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscription"
+}
+
+func (s WebhookSubscription) Subscribes(eventType string) bool {
+	for _, t := range strings.Split(s.EventTypes, ",") {
+		if t == "*" || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery logs an attempt to deliver an event to a subscription.
+type WebhookDelivery struct {
+	Id             uint64 `gorm:"primarykey"`
+	SubscriptionId uint64
+	EventId        string
+	EventType      string
+	Attempt        int
+	StatusCode     int
+	Success        bool
+	Error          string
+	DurationMs     int64
+	CreatedAt      time.Time
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/i18n"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+// statuses and messages of the well known errors, the detail of a wrapped error is
+// passed to the message as {{.detail}}. Errors without message are rendered as
+// themselves, translated when they wrap an i18n.Error.
+var errorStatuses = []struct {
+	err     error
+	status  int
+	code    string
+	message *i18n.Message
+}{
+	{gorm.ErrRecordNotFound, http.StatusNotFound, constant.ErrorCodeNotFound,
+		&i18n.Message{ID: "error.not_found", Other: "record not found"}},
+	{request.ErrPatchContentType, http.StatusUnsupportedMediaType, constant.ErrorCodeUnsupportedMedia,
+		&i18n.Message{ID: "error.patch_content_type", Other: request.ErrPatchContentType.Error()}},
+	{request.ErrInvalidPatch, http.StatusUnprocessableEntity, constant.ErrorCodeInvalidRequest,
+		&i18n.Message{ID: "error.invalid_patch", Other: "invalid patch: {{.detail}}"}},
+	{response.ErrForbidden, http.StatusForbidden, constant.ErrorCodeForbidden,
+		&i18n.Message{ID: "error.forbidden", Other: "You are not allowed to do this"}},
+	{response.ErrConflict, http.StatusConflict, constant.ErrorCodeConflict, nil},
+	{response.ErrBadRequest, http.StatusBadRequest, constant.ErrorCodeInvalidRequest, nil},
+}
+
+// message of the other errors, their text may hold queries, paths or other internals
+var internalErrorMessage = &i18n.Message{ID: "error.internal", Other: "Internal server error"}
+
+// ErrorMiddleware renders the errors raised by the handlers through util.Must in the
+// request language: validation errors as 400 with the field errors translated, the
+// errors of errorStatuses with their status and any other error as 500 with a generic
+// message, the error itself is only logged.
+func ErrorMiddleware(i18n *i18n.I18n) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			err, ok := p.(error)
+			if !ok {
+				err = fmt.Errorf("%v", p)
+			}
+			if errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+			// e.g. a failed binding already wrote its response, or a stream was cut
+			if c.Writer.Written() {
+				log.Ctx(c.Request.Context()).Error().Err(err).Msgf("%v %v failed after writing the response", c.Request.Method, c.Request.URL.Path)
+				c.Abort()
+				return
+			}
+			lang := ctxutil.GetLangFromCtx(c.Request.Context())
+			if fields := response.ErrorFieldsOf(err); fields != nil {
+				i18n.LocalizeErrorFields(lang, fields)
+				c.AbortWithStatusJSON(http.StatusBadRequest, response.Response{
+					ErrorCode:    constant.ErrorCodeValidation,
+					ErrorMessage: i18n.MustLocalize(lang, "error.validation", nil, "The request is invalid"),
+					ErrorFields:  fields,
+				})
+				return
+			}
+			for _, known := range errorStatuses {
+				if errors.Is(err, known.err) {
+					message := i18n.LocalizeError(lang, err)
+					if known.message != nil {
+						detail := strings.TrimPrefix(err.Error(), known.err.Error()+": ")
+						message = i18n.Localize(lang, known.message, map[string]interface{}{"detail": detail}, nil)
+					}
+					c.AbortWithStatusJSON(known.status, response.Response{
+						ErrorCode:    known.code,
+						ErrorMessage: message,
+					})
+					return
+				}
+			}
+			log.Ctx(c.Request.Context()).Error().Err(err).Msgf("%v %v failed", c.Request.Method, c.Request.URL.Path)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, response.Response{
+				ErrorCode:    constant.ErrorCodeInternal,
+				ErrorMessage: i18n.Localize(lang, internalErrorMessage, nil, nil),
+			})
+		}()
+		c.Next()
+	}
+}
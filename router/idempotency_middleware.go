@@ -0,0 +1,210 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"demo-curd/config"
+	"demo-curd/dto/response"
+	"demo-curd/i18n"
+	"demo-curd/model"
+	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"encoding/hex"
+	"fmt"
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// IdempotencyStore keeps the idempotency keys and the responses of their requests.
+type IdempotencyStore interface {
+	// Reserve saves record, or returns the unexpired record already holding its key.
+	Reserve(ctx context.Context, record *model.IdempotencyKey) (*model.IdempotencyKey, error)
+	// Complete saves the response of a reserved record.
+	Complete(ctx context.Context, record *model.IdempotencyKey) error
+	// Release forgets a reserved record whose request failed.
+	Release(ctx context.Context, record *model.IdempotencyKey) error
+}
+
+// IdempotencyMiddleware makes the POST requests sent with an Idempotency-Key header
+// safe to retry: the response of the first request is stored and replayed to the
+// retries, a key reused with another request is rejected with 422 and a key whose
+// request is still running with 409. Requests failing with a 5xx don't keep their key.
+type IdempotencyMiddleware struct {
+	Store IdempotencyStore
+	TTL   time.Duration
+	I18n  *i18n.I18n
+	// renders the errors raised by the handlers before their response is recorded
+	errors gin.HandlerFunc
+}
+
+var (
+	msgIdempotencyKeyTooLong = &i18n.Message{ID: "error.idempotency_key_too_long",
+		One:   "the {{.header}} header must be at most {{.max}} character",
+		Other: "the {{.header}} header must be at most {{.max}} characters"}
+	msgIdempotencyKeyReused = &i18n.Message{ID: "error.idempotency_key_reused",
+		Other: "the {{.header}} was already used with another request"}
+	msgIdempotencyKeyInUse = &i18n.Message{ID: "error.idempotency_key_in_use",
+		Other: "a request with this {{.header}} is still in progress"}
+)
+
+func NewIdempotencyMiddleware(c config.Config, store IdempotencyStore, i18n *i18n.I18n) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		Store:  store,
+		TTL:    c.Idempotency.Ttl,
+		I18n:   i18n,
+		errors: ErrorMiddleware(i18n),
+	}
+}
+
+func (m *IdempotencyMiddleware) MiddlewareFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(constant.HeaderIdempotencyKey)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > constant.IdempotencyKeyMaxLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, response.Response{
+				ErrorCode:    constant.ErrorCodeInvalidRequest,
+				ErrorMessage: m.localize(c, msgIdempotencyKeyTooLong, constant.IdempotencyKeyMaxLength),
+			})
+			return
+		}
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%v %v\n", c.Request.Method, c.Request.URL.RequestURI())
+		body, remove, err := spoolBody(c.Request.Body, hash)
+		util.Must(err)
+		defer remove()
+		c.Request.Body = body
+
+		record := &model.IdempotencyKey{
+			Scope:       idempotencyScope(c),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash.Sum(nil)),
+			ExpiresAt:   time.Now().Add(m.TTL),
+		}
+		existing, err := m.Store.Reserve(c.Request.Context(), record)
+		util.Must(err)
+		if existing != nil {
+			m.replay(c, existing, record.RequestHash)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		completed := false
+		// the outcome is saved even when the client has gone meanwhile
+		ctx := log.Ctx(c.Request.Context()).WithContext(context.Background())
+		defer func() {
+			// the handler panicked or failed, the retries must run it again
+			if !completed {
+				if err := m.Store.Release(ctx, record); err != nil {
+					log.Ctx(ctx).Error().Err(err).Msgf("Can't release idempotency key %v", key)
+				}
+			}
+		}()
+		// a 4xx raised through util.Must is a response to keep like the others
+		m.errors(c)
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		record.StatusCode = recorder.Status()
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Response = recorder.body.String()
+		if err := m.Store.Complete(ctx, record); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Can't save the response of idempotency key %v", key)
+			return
+		}
+		completed = true
+	}
+}
+
+func (m *IdempotencyMiddleware) replay(c *gin.Context, existing *model.IdempotencyKey, requestHash string) {
+	switch {
+	case existing.RequestHash != requestHash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, response.Response{
+			ErrorCode:    constant.ErrorCodeIdempotencyKeyReused,
+			ErrorMessage: m.localize(c, msgIdempotencyKeyReused, nil),
+		})
+	case !existing.Completed:
+		c.AbortWithStatusJSON(http.StatusConflict, response.Response{
+			ErrorCode:    constant.ErrorCodeIdempotencyKeyInUse,
+			ErrorMessage: m.localize(c, msgIdempotencyKeyInUse, nil),
+		})
+	default:
+		c.Header(constant.HeaderIdempotentReplayed, "true")
+		c.Data(existing.StatusCode, existing.ContentType, []byte(existing.Response))
+		c.Abort()
+	}
+}
+
+// localize renders message in the request language, the message can use {{.header}}
+// and {{.max}}.
+func (m *IdempotencyMiddleware) localize(c *gin.Context, message *i18n.Message, max interface{}) string {
+	data := map[string]interface{}{"header": constant.HeaderIdempotencyKey, "max": max}
+	return m.I18n.Localize(ctxutil.GetLangFromCtx(c.Request.Context()), message, data, max)
+}
+
+// idempotencyScope isolates the keys of the tenants and their users, the keys of the
+// anonymous callers of the public routes are isolated by client ip.
+func idempotencyScope(c *gin.Context) string {
+	userId, ok := jwt.ExtractClaims(c)[JWT_USER_ID]
+	if !ok || userId == nil {
+		return "ip:" + c.ClientIP()
+	}
+	return fmt.Sprintf("%v:%v", ctxutil.GetTenantFromCtx(c.Request.Context()), userId)
+}
+
+// spoolBody copies body to hash and returns a copy of it for the handlers. A body
+// larger than constant.IdempotencyMemoryBodySize, e.g. an import upload, is spooled to
+// a temp file rather than held in memory, remove deletes it.
+func spoolBody(body io.Reader, hash io.Writer) (spooled io.ReadCloser, remove func(), err error) {
+	var buf bytes.Buffer
+	if _, err = io.CopyN(io.MultiWriter(hash, &buf), body, constant.IdempotencyMemoryBodySize+1); err == io.EOF {
+		return io.NopCloser(&buf), func() {}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	remove = func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	if _, err = file.Write(buf.Bytes()); err == nil {
+		if _, err = io.Copy(io.MultiWriter(hash, file), body); err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+	}
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+	return io.NopCloser(file), remove, nil
+}
+
+// bodyRecorder keeps a copy of the response body written to the client.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
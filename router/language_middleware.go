@@ -0,0 +1,21 @@
+package router
+
+import (
+	"demo-curd/i18n"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"github.com/gin-gonic/gin"
+)
+
+// LanguageMiddleware negotiates the language of the response among the configured
+// ones from the Accept-Language header, with its q-values, and puts it on the context
+// of the request, see ctxutil.GetLangFromCtx.
+func LanguageMiddleware(i18n *i18n.I18n) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Match(c.GetHeader(constant.HeaderAcceptLanguage))
+		c.Header(constant.HeaderContentLanguage, lang)
+		c.Writer.Header().Add(constant.HeaderVary, constant.HeaderAcceptLanguage)
+		c.Request = c.Request.WithContext(ctxutil.ContextWithLang(c.Request.Context(), lang))
+		c.Next()
+	}
+}
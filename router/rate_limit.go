@@ -0,0 +1,180 @@
+package router
+
+import (
+	"context"
+	"demo-curd/config"
+	"demo-curd/dto/response"
+	"demo-curd/i18n"
+	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"fmt"
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimit is a token bucket holding up to Burst tokens and refilled with Rate tokens
+// per second, every request takes a token.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// wait before the next token, when not allowed
+	RetryAfter time.Duration
+	// wait before the bucket is full again
+	Reset time.Duration
+}
+
+// RateLimitStore keeps the token buckets, the in-memory store limits each instance on
+// its own, a store shared by the instances, e.g. on Redis, limits them as a whole.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key, a missing bucket starts full.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+	// Return gives back a token taken from the bucket of key, the bucket stays at most full.
+	Return(ctx context.Context, key string, limit RateLimit) error
+}
+
+// NewRateLimitStore returns the store of rateLimit.store, add the case of a new store
+// here.
+func NewRateLimitStore(c config.Config) (RateLimitStore, error) {
+	switch c.RateLimit.Store {
+	case "", constant.RateLimitStoreMemory:
+		return NewMemoryRateLimitStore(), nil
+	default:
+		return nil, fmt.Errorf("rateLimit.store: unsupported store %v", c.RateLimit.Store)
+	}
+}
+
+type rateLimitPolicy struct {
+	name  string
+	urls  []string
+	key   string
+	limit RateLimit
+}
+
+// RateLimiter applies the policies of the rateLimit configuration, a request takes a
+// token of every policy matching its route and is rejected with 429 when one of them
+// is empty, the tokens it took from the other policies are then given back. It must run
+// after the auth middleware for the policies keyed by user.
+type RateLimiter struct {
+	Store RateLimitStore
+	I18n  *i18n.I18n
+	// *rateLimits of the last reload
+	limits atomic.Value
+}
+
+type rateLimits struct {
+	enabled  bool
+	policies []rateLimitPolicy
+}
+
+var msgRateLimited = &i18n.Message{ID: "error.rate_limited", Other: "too many requests, retry later"}
+
+func NewRateLimiter(c config.Config, store RateLimitStore, i18n *i18n.I18n) *RateLimiter {
+	r := &RateLimiter{Store: store, I18n: i18n}
+	_ = r.Reload(c)
+	return r
+}
+
+// Reload applies the policies of c to the following requests, the buckets are kept.
+func (r *RateLimiter) Reload(c config.Config) error {
+	policies := make([]rateLimitPolicy, 0, len(c.RateLimit.Policies))
+	for _, p := range c.RateLimit.Policies {
+		policies = append(policies, rateLimitPolicy{
+			name:  p.Name,
+			urls:  p.Urls,
+			key:   p.Key,
+			limit: RateLimit{Rate: float64(p.Rate) / p.Period.Seconds(), Burst: p.Burst},
+		})
+	}
+	r.limits.Store(&rateLimits{
+		enabled:  c.RateLimit.Enabled,
+		policies: policies,
+	})
+	return nil
+}
+
+func (r *RateLimiter) MiddlewareFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limits := r.limits.Load().(*rateLimits)
+		if !limits.enabled {
+			c.Next()
+			return
+		}
+		var tightest *RateLimitResult
+		var tightestLimit RateLimit
+		var taken []rateLimitPolicy
+		for _, policy := range limits.policies {
+			if !policy.matches(c) {
+				continue
+			}
+			res, err := r.Store.Take(c.Request.Context(), policy.name+":"+policy.bucket(c), policy.limit)
+			util.Must(err)
+			if tightest == nil || !res.Allowed || res.Remaining < tightest.Remaining {
+				tightest, tightestLimit = &res, policy.limit
+			}
+			if !res.Allowed {
+				// a rejected request doesn't count against the policies it passed
+				for _, p := range taken {
+					util.Must(r.Store.Return(c.Request.Context(), p.name+":"+p.bucket(c), p.limit))
+				}
+				break
+			}
+			taken = append(taken, policy)
+		}
+		if tightest == nil {
+			c.Next()
+			return
+		}
+		c.Header(constant.HeaderRateLimitLimit, strconv.Itoa(tightestLimit.Burst))
+		c.Header(constant.HeaderRateLimitRemaining, strconv.Itoa(tightest.Remaining))
+		c.Header(constant.HeaderRateLimitReset, strconv.Itoa(ceilSeconds(tightest.Reset)))
+		if !tightest.Allowed {
+			c.Header(constant.HeaderRetryAfter, strconv.Itoa(ceilSeconds(tightest.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, response.Response{
+				ErrorCode:    constant.ErrorCodeRateLimited,
+				ErrorMessage: r.I18n.Localize(ctxutil.GetLangFromCtx(c.Request.Context()), msgRateLimited, nil, nil),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (p rateLimitPolicy) matches(c *gin.Context) bool {
+	for _, url := range p.urls {
+		if matchRequest(c, url) {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket returns the bucket of the request within the policy, anonymous requests are
+// limited by IP by the user policies.
+func (p rateLimitPolicy) bucket(c *gin.Context) string {
+	switch p.key {
+	case constant.RateLimitKeyUser:
+		if userId, ok := jwt.ExtractClaims(c)[JWT_USER_ID]; ok && userId != nil {
+			return fmt.Sprintf("user:%v", userId)
+		}
+		return "ip:" + c.ClientIP()
+	case constant.RateLimitKeyRoute:
+		return c.Request.Method + " " + c.FullPath()
+	default:
+		return "ip:" + c.ClientIP()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// buckets untouched for this long are full again and can be forgotten
+const memoryRateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore keeps the token buckets in memory, each instance limits its own
+// requests.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	// time tokens was computed at
+	updatedAt time.Time
+	// time the bucket is full again
+	fullAt time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*limit.Rate)
+	bucket.updatedAt = now
+
+	res := RateLimitResult{Allowed: bucket.tokens >= 1}
+	if res.Allowed {
+		bucket.tokens--
+	} else {
+		res.RetryAfter = secondsDuration((1 - bucket.tokens) / limit.Rate)
+	}
+	res.Remaining = int(bucket.tokens)
+	res.Reset = secondsDuration((float64(limit.Burst) - bucket.tokens) / limit.Rate)
+	bucket.fullAt = now.Add(res.Reset)
+	return res, nil
+}
+
+func (s *MemoryRateLimitStore) Return(_ context.Context, key string, limit RateLimit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if bucket, ok := s.buckets[key]; ok {
+		bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+1)
+		bucket.fullAt = bucket.updatedAt.Add(secondsDuration((float64(limit.Burst) - bucket.tokens) / limit.Rate))
+	}
+	return nil
+}
+
+// sweep forgets the full buckets, at most once per sweep interval.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryRateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"crypto/rand"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"time"
+)
+
+// RequestIdMiddleware identifies every request by the X-Request-ID header of the
+// client, or a random id, sent back in the response. The context of the request
+// carries the id and a logger adding it, along with the trace id when the request is
+// traced, to every log, see log.Ctx.
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(constant.HeaderRequestId)
+		if !validRequestId(requestId) {
+			requestId = newRequestId()
+		}
+		c.Header(constant.HeaderRequestId, requestId)
+		logCtx := log.With().Str("request_id", requestId)
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			logCtx = logCtx.Str("trace_id", span.TraceID().String())
+		}
+		logger := logCtx.Logger()
+		ctx := ctxutil.ContextWithRequestId(c.Request.Context(), requestId)
+		c.Request = c.Request.WithContext(logger.WithContext(ctx))
+		c.Next()
+	}
+}
+
+// AccessLogMiddleware logs every request once handled, the server errors as errors and
+// the client ones as warnings. It must run after RequestIdMiddleware.
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		status := c.Writer.Status()
+		logger := log.Ctx(c.Request.Context())
+		var event *zerolog.Event
+		switch {
+		case status >= http.StatusInternalServerError:
+			event = logger.Error()
+		case status >= http.StatusBadRequest:
+			event = logger.Warn()
+		default:
+			event = logger.Info()
+		}
+		event.Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Int("status", status).
+			Dur("latency", time.Since(start)).
+			Str("ip", c.ClientIP()).
+			Int("size", c.Writer.Size()).
+			Msgf("%v %v", c.Request.Method, c.Request.URL.Path)
+	}
+}
+
+// validRequestId accepts the printable ascii ids of reasonable length, the id is
+// logged and sent back as is.
+func validRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > constant.RequestIdMaxLength {
+		return false
+	}
+	for _, r := range requestId {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -4,20 +4,23 @@ import (
 	"crypto/rsa"
 	"demo-curd/config"
 	"demo-curd/i18n"
+	"demo-curd/tracing"
 	"demo-curd/util"
 	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
 	"errors"
 	"fmt"
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/bmatcuk/doublestar/v3"
 	"github.com/gin-contrib/cors"
-	"github.com/gin-contrib/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -35,35 +38,52 @@ type Router struct {
 	PrivateKey               *rsa.PrivateKey
 	LongRefreshExpTime       time.Duration
 	CustomAuthorizedHandlers []CustomAuthorizedHandler
+	// config of the last reload, for the cors and security settings
+	config atomic.Value
+	cors   atomic.Value
 }
 
 type CustomAuthorizedHandler interface {
 	Authorize(c *gin.Context, authenticationData interface{}, authorities []interface{}) bool
 }
 
-func NewRouterWithoutAuthMw(c config.Config, i18n *i18n.I18n) (*Router, error) {
-	return NewRouter(c, i18n, jwt.GinJWTMiddleware{})
+func NewRouterWithoutAuthMw(c config.Config, i18n *i18n.I18n, t *tracing.Tracing) (*Router, error) {
+	return NewRouter(c, i18n, t, jwt.GinJWTMiddleware{})
 }
 
-func NewRouter(c config.Config, i18n *i18n.I18n, jwtMdw jwt.GinJWTMiddleware) (*Router, error) {
+func NewRouter(c config.Config, i18n *i18n.I18n, t *tracing.Tracing, jwtMdw jwt.GinJWTMiddleware) (*Router, error) {
 	e := gin.New()
 
 	e.RedirectTrailingSlash = true
 	e.RedirectFixedPath = true
+	// gin trusts every proxy by default, any client could pick its IP
+	if err := e.SetTrustedProxies(c.Server.TrustedProxies); err != nil {
+		return nil, err
+	}
 
-	//e.Use(gin.Logger())
-	e.Use(logger.SetLogger())
+	e.Use(otelgin.Middleware(c.Tracing.ServiceName, otelgin.WithTracerProvider(t.Provider)))
+	e.Use(RequestIdMiddleware(), AccessLogMiddleware())
+	e.Use(LanguageMiddleware(i18n))
+	e.Use(ErrorMiddleware(i18n))
 
-	// CORS
-	corsMiddleware, err := initCorsMiddleware(c)
-	if err != nil {
+	r := &Router{
+		Engine:                   e,
+		I18n:                     i18n,
+		LongRefreshExpTime:       c.Jwt.RefreshExpTime,
+		CustomAuthorizedHandlers: make([]CustomAuthorizedHandler, 0),
+	}
+	if err := r.Reload(c); err != nil {
 		return nil, err
 	}
-	e.Use(corsMiddleware)
+
+	// CORS
+	e.Use(func(ctx *gin.Context) {
+		r.cors.Load().(gin.HandlerFunc)(ctx)
+	})
 
 	// the jwt middleware
 	customAuthorizedHandlers := make([]CustomAuthorizedHandler, 0)
-	authMiddleware, err := initJwtMiddleware(c, jwtMdw, customAuthorizedHandlers)
+	authMiddleware, err := initJwtMiddleware(c, jwtMdw, r.Config, customAuthorizedHandlers)
 	if err != nil {
 		log.Fatal().Err(err).Msg("JWT Error:" + err.Error())
 		return nil, err
@@ -78,30 +98,29 @@ func NewRouter(c config.Config, i18n *i18n.I18n, jwtMdw jwt.GinJWTMiddleware) (*
 		return nil, errInit
 	}
 
-	refreshExpTime, err := time.ParseDuration(c.Jwt.RefreshExpTime)
-	if err != nil {
-		return nil, err
-	}
-	return &Router{
-		Engine:                   e,
-		AuthMiddleware:           authMiddleware,
-		I18n:                     i18n,
-		LongRefreshExpTime:       refreshExpTime,
-		PrivateKey:               privateKey(authMiddleware),
-		CustomAuthorizedHandlers: make([]CustomAuthorizedHandler, 0),
-	}, nil
+	r.AuthMiddleware = authMiddleware
+	r.PrivateKey = privateKey(authMiddleware)
+	return r, nil
 }
 
-func initJwtMiddleware(c config.Config, jwtMdw jwt.GinJWTMiddleware, handlers []CustomAuthorizedHandler) (*jwt.GinJWTMiddleware, error) {
-	expiredTime, err := time.ParseDuration(c.Jwt.ExpiredTime)
-	if err != nil {
-		return nil, err
-	}
-	refreshExpTime, err := time.ParseDuration(c.Jwt.RefreshExpTime)
+// Reload applies the cors and security settings of c to the following requests.
+func (r *Router) Reload(c config.Config) error {
+	corsMiddleware, err := initCorsMiddleware(c)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defAuthorizedMw := DefAuthorizedMw(c, handlers)
+	r.cors.Store(corsMiddleware)
+	r.config.Store(c)
+	return nil
+}
+
+// Config returns the config of the last reload.
+func (r *Router) Config() config.Config {
+	return r.config.Load().(config.Config)
+}
+
+func initJwtMiddleware(c config.Config, jwtMdw jwt.GinJWTMiddleware, current func() config.Config, handlers []CustomAuthorizedHandler) (*jwt.GinJWTMiddleware, error) {
+	defAuthorizedMw := DefAuthorizedMw(current, handlers)
 
 	authenticator := jwtMdw.Authenticator
 	payloadFunc := jwtMdw.PayloadFunc
@@ -117,8 +136,8 @@ func initJwtMiddleware(c config.Config, jwtMdw jwt.GinJWTMiddleware, handlers []
 		Realm:            c.Jwt.Realm,
 		SigningAlgorithm: c.Jwt.SigningAlg,
 		Key:              []byte(c.Jwt.Secret),
-		Timeout:          expiredTime,
-		MaxRefresh:       refreshExpTime,
+		Timeout:          c.Jwt.ExpiredTime,
+		MaxRefresh:       c.Jwt.RefreshExpTime,
 		IdentityKey:      JWT_IDENTITY_KEY,
 		Authenticator:    authenticator,
 		PayloadFunc:      payloadFunc,
@@ -146,19 +165,20 @@ func initJwtMiddleware(c config.Config, jwtMdw jwt.GinJWTMiddleware, handlers []
 }
 
 func initCorsMiddleware(c config.Config) (gin.HandlerFunc, error) {
-	maxAge, err := time.ParseDuration(c.CORS.MaxAge)
-	if err != nil {
-		return nil, nil
-	}
-
-	return cors.New(cors.Config{
+	corsConfig := cors.Config{
 		AllowOrigins:     c.CORS.AllowOrigins,
 		AllowMethods:     c.CORS.AllowMethods,
 		AllowHeaders:     c.CORS.AllowHeaders,
 		ExposeHeaders:    c.CORS.ExposeHeaders,
 		AllowCredentials: c.CORS.AllowCredentials,
-		MaxAge:           maxAge,
-	}), nil
+		MaxAge:           c.CORS.MaxAge,
+	}
+	// cors.New panics on an invalid config, Validate changes the config it checks
+	check := corsConfig
+	if err := check.Validate(); err != nil {
+		return nil, fmt.Errorf("cors: %w", err)
+	}
+	return cors.New(corsConfig), nil
 }
 
 func privateKey(privKeyFile *jwt.GinJWTMiddleware) *rsa.PrivateKey {
@@ -169,19 +189,20 @@ func privateKey(privKeyFile *jwt.GinJWTMiddleware) *rsa.PrivateKey {
 	return value.(*rsa.PrivateKey)
 }
 
-func DefAuthorizedMw(cfg config.Config, handlers []CustomAuthorizedHandler) jwt.GinJWTMiddleware {
+// DefAuthorizedMw authorizes the requests with the security rules of cfg, called on
+// every request so the rules can be reloaded.
+func DefAuthorizedMw(cfg func() config.Config, handlers []CustomAuthorizedHandler) jwt.GinJWTMiddleware {
 	return jwt.GinJWTMiddleware{
 		IdentityHandler: func(c *gin.Context) interface{} {
 			claims := jwt.ExtractClaims(c)
-			log.Debug().Msgf("IdentityHandler, userId: %v", claims[JWT_USER_ID])
+			log.Ctx(c.Request.Context()).Debug().Msgf("IdentityHandler, userId: %v", claims[JWT_USER_ID])
 			return claims[JWT_USER_ID]
 		},
 		Authorizator: func(data interface{}, c *gin.Context) bool {
 			claims := jwt.ExtractClaims(c)
 			authorities := claims[JWT_AUTHORITIES].([]interface{})
-			log.Debug().Msgf("Authorizator, identity data: %v", data)
-			log.Debug().Msgf("authorities: %v", authorities)
-			return HandleAuthorizationWithAuthorities(data, c, cfg, authorities, handlers)
+			log.Ctx(c.Request.Context()).Debug().Msgf("Authorizator, identity data: %v, authorities: %v", data, authorities)
+			return HandleAuthorizationWithAuthorities(data, c, cfg(), authorities, handlers)
 		},
 	}
 }
@@ -203,45 +224,45 @@ func HandleAuthorizationWithAuthorities(data interface{}, c *gin.Context, cfg co
 	return false
 }
 
+// authorizePerUrl returns whether the request is authorized by req and whether url
+// matches the request, the first matching rule decides.
 func authorizePerUrl(data interface{}, c *gin.Context, url string, req config.ConfigAuthorizedRequests, authorities []interface{}, handlers []CustomAuthorizedHandler) (bool, bool) {
-	arrUrl := strings.Split(url, ":")
-	pathMatched, err := doublestar.Match(arrUrl[0], c.FullPath())
-	util.Must(err)
-	if pathMatched {
-		if len(arrUrl) <= 1 {
-			return false, false
-		}
-
-		methodPatched, err := doublestar.Match(arrUrl[1], c.Request.Method)
-		util.Must(err)
-		if !methodPatched {
-			return false, false
-		}
-		if req.Access == constant.AccessHasPermission {
-			if auth := authorizeHasPermission(req, authorities); auth {
-				return true, true
-			}
-		} else if req.Access == constant.AccessHasRole {
-			if auth := authorizeHasRole(req, authorities); auth {
+	if !matchRequest(c, url) {
+		return false, false
+	}
+	switch req.Access {
+	case constant.AccessHasPermission:
+		return authorizeHasPermission(req, authorities), true
+	case constant.AccessHasRole:
+		return authorizeHasRole(req, authorities), true
+	case constant.AccessPermitAll:
+		return true, true
+	case constant.AccessDenyAll:
+		return false, true
+	case constant.AccessCustom:
+		for _, h := range handlers {
+			if h.Authorize(c, data, authorities) {
 				return true, true
 			}
-		} else if req.Access == constant.AccessPermitAll {
-			return true, true
-		} else if req.Access == constant.AccessDenyAll {
-			return false, true
-		} else if req.Access == constant.AccessCustom {
-			if handlers != nil && len(handlers) > 0 {
-				for _, h := range handlers {
-					if auth := h.Authorize(c, data, authorities); auth {
-						return true, true
-					}
-				}
-			}
-		} else {
-			panic(errors.New("Invalid access type, must be has permission, has role, permit all or deny all"))
 		}
+		return false, true
+	default:
+		panic(errors.New("Invalid access type, must be has permission, has role, permit all or deny all"))
 	}
-	return false, false
+}
+
+// matchRequest reports whether the route and the method of the request match url, a
+// "<path pattern>:<method pattern>" doublestar pattern, e.g. /api/v1/**:POST.
+func matchRequest(c *gin.Context, url string) bool {
+	arrUrl := strings.Split(url, ":")
+	pathMatched, err := doublestar.Match(arrUrl[0], c.FullPath())
+	util.Must(err)
+	if !pathMatched || len(arrUrl) <= 1 {
+		return false
+	}
+	methodMatched, err := doublestar.Match(arrUrl[1], c.Request.Method)
+	util.Must(err)
+	return methodMatched
 }
 
 func authorizeHasPermission(req config.ConfigAuthorizedRequests, authorities []interface{}) bool {
@@ -264,6 +285,31 @@ func authorizeHasRole(v config.ConfigAuthorizedRequests, authorities []interface
 	return false
 }
 
+// TenantMiddleware binds the company id claim of the JWT to the request context as
+// the tenant, along with the user id and authorities claims, it must run after the
+// auth middleware.
+func TenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := jwt.ExtractClaims(c)
+		ctx := c.Request.Context()
+		if companyId, ok := claims[constant.COMPANY_ID]; ok && companyId != nil {
+			ctx = ctxutil.ContextWithTenant(ctx, fmt.Sprint(companyId))
+		}
+		if userId, ok := claims[JWT_USER_ID]; ok && userId != nil {
+			ctx = ctxutil.ContextWithUser(ctx, fmt.Sprint(userId))
+		}
+		if authorities, ok := claims[JWT_AUTHORITIES].([]interface{}); ok {
+			values := make([]string, 0, len(authorities))
+			for _, a := range authorities {
+				values = append(values, fmt.Sprint(a))
+			}
+			ctx = ctxutil.ContextWithAuthorities(ctx, values)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
 func (r *Router) RegisterCustomAuthorizedHandler(cah CustomAuthorizedHandler) {
 	r.CustomAuthorizedHandlers = append(r.CustomAuthorizedHandlers, cah)
 }
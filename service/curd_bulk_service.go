@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/i18n"
+	"demo-curd/model"
+	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"errors"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/jinzhu/copier"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBulkTooManyItems = response.BadRequest(i18n.NewError(&i18n.Message{ID: "error.bulk_too_many_items",
+		One:   "a bulk request accepts at most {{.max}} item",
+		Other: "a bulk request accepts at most {{.max}} items"},
+		map[string]interface{}{"max": constant.BulkMaxItems}, constant.BulkMaxItems))
+	errBulkAborted = errors.New("not written because other items failed")
+	// rendered as 404 by router.ErrorMiddleware
+	errNotFound = gorm.ErrRecordNotFound
+)
+
+// BulkCreate validates every item and inserts the valid ones in batches. In all or
+// nothing mode a single invalid item aborts the whole request.
+func (s *CurdService) BulkCreate(ctx context.Context, dtos []request.CurdDTO, mode request.BulkMode) (*response.BulkResult, error) {
+	if len(dtos) > constant.BulkMaxItems {
+		return nil, ErrBulkTooManyItems
+	}
+	result := response.NewBulkResult(len(dtos))
+	curds := make([]model.Curd, 0, len(dtos))
+	indexes := make([]int, 0, len(dtos))
+	valid := make([]*request.CurdDTO, 0, len(dtos))
+	for i := range dtos {
+		if err := dtos[i].Validate(); err != nil {
+			result.Fail(i, 0, constant.ErrorCodeValidation, err)
+			continue
+		}
+		valid = append(valid, &dtos[i])
+		indexes = append(indexes, i)
+	}
+	errs, err := s.CurdValidator.ValidateAll(ctx, valid, make([]uint, len(valid)))
+	if err != nil {
+		return nil, err
+	}
+	tenantId := ctxutil.GetTenantFromCtx(ctx)
+	validIndexes := indexes
+	indexes = make([]int, 0, len(validIndexes))
+	for j, i := range validIndexes {
+		if errs[j] != nil {
+			result.Fail(i, 0, constant.ErrorCodeValidation, errs[j])
+			continue
+		}
+		curd := model.Curd{TenantId: tenantId}
+		util.Must(copier.Copy(&curd, &dtos[i]))
+		curds = append(curds, curd)
+		indexes = append(indexes, i)
+	}
+	if mode == request.BulkModeAllOrNothing && len(curds) < len(dtos) {
+		result.Abort(constant.ErrorCodeSkipped, errBulkAborted)
+		return result.Count(), nil
+	}
+
+	var created []model.Curd
+	err = s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		// insert a copy, ids assigned by a rolled back batch must not leak into the retry
+		created, err = s.CurdDao.CreateInBatches(ctx, append([]model.Curd(nil), curds...), constant.BulkBatchSize)
+		if err != nil {
+			return err
+		}
+		return s.WebhookService.Publish(ctx, constant.WebhookEventCurdCreated, curdEvents(created)...)
+	})
+	if err == nil {
+		for j, curd := range created {
+			result.Succeed(indexes[j], curd.Id)
+		}
+		s.invalidate(ctx, result.SucceededIds()...)
+		return result.Count(), nil
+	}
+	if mode == request.BulkModeAllOrNothing {
+		return nil, emailTakenError(err)
+	}
+
+	// a batch failed, insert one by one to find out which rows the database rejects
+	for j := range curds {
+		err := s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := s.CurdDao.Create(ctx, &curds[j]); err != nil {
+				return emailTakenError(err)
+			}
+			return s.WebhookService.Publish(ctx, constant.WebhookEventCurdCreated, curdEvents(curds[j:j+1])...)
+		})
+		if err != nil {
+			result.Fail(indexes[j], 0, failureCode(err), err)
+			continue
+		}
+		result.Succeed(indexes[j], curds[j].Id)
+	}
+	s.invalidate(ctx, result.SucceededIds()...)
+	return result.Count(), nil
+}
+
+// BulkUpdate replaces the fields of every item identified by its id.
+func (s *CurdService) BulkUpdate(ctx context.Context, dtos []request.CurdBulkUpdateDTO, mode request.BulkMode) (*response.BulkResult, error) {
+	if len(dtos) > constant.BulkMaxItems {
+		return nil, ErrBulkTooManyItems
+	}
+	result := response.NewBulkResult(len(dtos))
+	valid := make([]*request.CurdDTO, 0, len(dtos))
+	validIds := make([]uint, 0, len(dtos))
+	indexes := make([]int, 0, len(dtos))
+	for i := range dtos {
+		if err := dtos[i].Validate(); err != nil {
+			result.Fail(i, uint(dtos[i].Id), constant.ErrorCodeValidation, err)
+			continue
+		}
+		valid = append(valid, &dtos[i].CurdDTO)
+		validIds = append(validIds, uint(dtos[i].Id))
+		indexes = append(indexes, i)
+	}
+	errs, err := s.CurdValidator.ValidateAll(ctx, valid, validIds)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, 0, len(dtos))
+	for j, i := range indexes {
+		if errs[j] != nil {
+			result.Fail(i, uint(dtos[i].Id), constant.ErrorCodeValidation, errs[j])
+			continue
+		}
+		ids = append(ids, dtos[i].Id)
+	}
+	if mode == request.BulkModeAllOrNothing && len(ids) < len(dtos) {
+		result.Abort(constant.ErrorCodeSkipped, errBulkAborted)
+		return result.Count(), nil
+	}
+
+	err = s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		existing, err := s.CurdDao.FindByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		byId := make(map[uint64]model.Curd, len(existing))
+		for _, curd := range existing {
+			byId[uint64(curd.Id)] = curd
+		}
+
+		failed := false
+		for i := range dtos {
+			if result.Items[i].ErrorCode != "" {
+				continue
+			}
+			curd, ok := byId[dtos[i].Id]
+			if !ok {
+				result.Fail(i, uint(dtos[i].Id), constant.ErrorCodeNotFound, errNotFound)
+				failed = true
+				continue
+			}
+			util.Must(copier.Copy(&curd, &dtos[i].CurdDTO))
+			update := func(ctx context.Context) error {
+				if _, err := s.CurdDao.Update(ctx, &curd); err != nil {
+					return emailTakenError(err)
+				}
+				return s.WebhookService.Publish(ctx, constant.WebhookEventCurdUpdated, curdEvents([]model.Curd{curd})...)
+			}
+			if mode == request.BulkModePartial {
+				// a savepoint per item keeps the successful updates when one fails
+				err = s.TxManager.WithinTx(ctx, update)
+			} else {
+				err = update(ctx)
+			}
+			if err != nil {
+				if mode == request.BulkModeAllOrNothing {
+					return err
+				}
+				result.Fail(i, curd.Id, failureCode(err), err)
+				continue
+			}
+			result.Succeed(i, curd.Id)
+		}
+		if failed && mode == request.BulkModeAllOrNothing {
+			return errBulkAborted
+		}
+		return nil
+	})
+	if errors.Is(err, errBulkAborted) {
+		result.Abort(constant.ErrorCodeSkipped, errBulkAborted)
+		return result.Count(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, result.SucceededIds()...)
+	return result.Count(), nil
+}
+
+// BulkDelete soft deletes the records with the given ids, unknown ids are reported
+// as not found.
+func (s *CurdService) BulkDelete(ctx context.Context, ids []uint64, mode request.BulkMode) (*response.BulkResult, error) {
+	if len(ids) > constant.BulkMaxItems {
+		return nil, ErrBulkTooManyItems
+	}
+	result := response.NewBulkResult(len(ids))
+	err := s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		existing, err := s.CurdDao.FindByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		found := make(map[uint64]bool, len(existing))
+		for _, curd := range existing {
+			found[uint64(curd.Id)] = true
+		}
+
+		deleteIds := make([]uint64, 0, len(existing))
+		for i, id := range ids {
+			if !found[id] {
+				result.Fail(i, uint(id), constant.ErrorCodeNotFound, errNotFound)
+				continue
+			}
+			result.Succeed(i, uint(id))
+			deleteIds = append(deleteIds, id)
+		}
+		if mode == request.BulkModeAllOrNothing && len(deleteIds) < len(ids) {
+			return errBulkAborted
+		}
+		if _, err = s.CurdDao.DeleteByIDs(ctx, deleteIds); err != nil {
+			return err
+		}
+		// the event carries the record as it was before the deletion
+		return s.WebhookService.Publish(ctx, constant.WebhookEventCurdDeleted, curdEvents(existing)...)
+	})
+	if errors.Is(err, errBulkAborted) {
+		result.Abort(constant.ErrorCodeSkipped, errBulkAborted)
+		return result.Count(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, result.SucceededIds()...)
+	return result.Count(), nil
+}
+
+// failureCode is the error code of an item the database rejected.
+func failureCode(err error) string {
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		return constant.ErrorCodeValidation
+	}
+	return constant.ErrorCodeDatabase
+}
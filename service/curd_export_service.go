@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"demo-curd/dao"
+	"demo-curd/dto/request"
+	"demo-curd/i18n"
+	"demo-curd/model"
+	"demo-curd/util/dbutil"
+	"demo-curd/util/sheetutil"
+	"io"
+)
+
+// exported columns, the headers are localized with the "curd.<key>" messages
+var curdExportKeys = []string{"id", "name", "email", "phone", "city", "created_at", "updated_at"}
+
+var curdExportHeaders = map[string]string{
+	"id":         "Id",
+	"name":       "Name",
+	"email":      "Email",
+	"phone":      "Phone",
+	"city":       "City",
+	"created_at": "Created at",
+	"updated_at": "Updated at",
+}
+
+type CurdExportService struct {
+	CurdDao *dao.CurdDao
+	I18n    *i18n.I18n
+}
+
+// CheckExport validates the sort before anything is written to the response.
+func (s *CurdExportService) CheckExport(sort string) error {
+	_, err := dbutil.ParseSort(sort, curdSortColumns...)
+	return err
+}
+
+// Export streams the curds matching filter to w, rows are read from the database one
+// at a time.
+func (s *CurdExportService) Export(ctx context.Context, w io.Writer, format sheetutil.Format, lang string, filter request.CurdFilterDTO, sort string) error {
+	headers := make([]string, len(curdExportKeys))
+	for i, key := range curdExportKeys {
+		headers[i] = s.I18n.MustLocalize(lang, "curd."+key, nil, curdExportHeaders[key])
+	}
+	writer, err := sheetutil.NewWriter(w, format, curdExportKeys, headers)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(curdExportKeys))
+	err = s.CurdDao.Each(ctx, func(curd *model.Curd) error {
+		values[0], values[1], values[2], values[3] = curd.Id, curd.Name, curd.Email, curd.Phone
+		values[4], values[5], values[6] = curd.City, curd.CreatedAt, curd.UpdatedAt
+		return writer.Write(values)
+	}, curdFilter(filter), dbutil.Sort(sort, curdSortColumns...))
+	if err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
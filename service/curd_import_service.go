@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	"demo-curd/config"
+	"demo-curd/dao"
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/job"
+	"demo-curd/model"
+	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"demo-curd/util/sheetutil"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/jinzhu/copier"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// curd fields a spreadsheet column can be mapped to
+var curdImportFields = []string{"name", "email", "phone", "city"}
+
+var (
+	ErrImportJobNotFound = fmt.Errorf("import job: %w", gorm.ErrRecordNotFound)
+
+	ErrFileTooLarge          = validation.NewError("validation_file_too_large", "must be at most {{.max}} bytes")
+	ErrFileTypeInvalid       = validation.NewError("validation_file_type_invalid", "must be a csv or xlsx file")
+	ErrFileEmpty             = validation.NewError("validation_file_empty", "must have a header row")
+	ErrMappingInvalid        = validation.NewError("validation_mapping_invalid", "must be a JSON object of column headers to curd fields")
+	ErrMappingUnknownField   = validation.NewError("validation_mapping_unknown_field", "maps the column {{.column}} to the unknown field {{.field}}")
+	ErrMappingDuplicateField = validation.NewError("validation_mapping_duplicate_field", "maps several columns to the field {{.field}}")
+	ErrMappingNameMissing    = validation.NewError("validation_mapping_name_missing", "must map a column to the field name")
+)
+
+// CurdImportService imports curds from csv and xlsx files in curd.import jobs, so any
+// instance runs them and their state outlives a restart. Valid rows are inserted in
+// batches, rejected rows are written to a downloadable csv report.
+type CurdImportService struct {
+	CurdDao        *dao.CurdDao
+	CachedCurdDao  *dao.CachedCurdDao
+	CurdImportDao  *dao.CurdImportDao
+	CurdValidator  *CurdValidator
+	TxManager      *TxManager
+	WebhookService *WebhookService
+	JobManager     *job.Manager
+	// uploaded files and reports, see import.dir
+	Dir string
+}
+
+// payload of the curd.import jobs
+type curdImportJob struct {
+	ImportId string         `json:"import_id"`
+	Columns  map[int]string `json:"columns"`
+}
+
+// pending row waiting for its batch to be inserted
+type importRow struct {
+	number int
+	values []string
+	dto    request.CurdDTO
+}
+
+// errImportInterrupted fails an import whose job is run again, e.g. after its instance
+// crashed, the rows imported before can't be told apart from the others.
+var errImportInterrupted = errors.New("the import was interrupted, upload the file again")
+
+// NewCurdImportService registers the curd.import handler, it can't be listed in
+// NewJobHandlers since the import enqueues the webhook deliveries.
+func NewCurdImportService(c config.Config, curdDao *dao.CurdDao, cachedCurdDao *dao.CachedCurdDao, curdImportDao *dao.CurdImportDao,
+	curdValidator *CurdValidator, txManager *TxManager, webhookService *WebhookService, jobManager *job.Manager) (*CurdImportService, error) {
+	if err := os.MkdirAll(c.Import.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &CurdImportService{
+		CurdDao:        curdDao,
+		CachedCurdDao:  cachedCurdDao,
+		CurdImportDao:  curdImportDao,
+		CurdValidator:  curdValidator,
+		TxManager:      txManager,
+		WebhookService: webhookService,
+		JobManager:     jobManager,
+		Dir:            c.Import.Dir,
+	}
+	if err := jobManager.Register(job.HandlerFunc(constant.ImportJobType, s.run)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start checks the header row against mapping and enqueues the import of the remaining
+// rows. mapping maps column headers to curd fields, columns left out of it are mapped
+// by header name. The rows are imported into the tenant of ctx, the import is only
+// visible to the user of ctx. The file at path, in Dir, is removed once processed.
+func (s *CurdImportService) Start(ctx context.Context, fileName string, path string, mapping map[string]string, dryRun bool) (*response.ImportJobDTO, error) {
+	format, err := sheetutil.FormatOf(fileName)
+	if err != nil {
+		return nil, validation.Errors{"file": ErrFileTypeInvalid}
+	}
+	reader, err := sheetutil.Open(path, format)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if !reader.Next() {
+		if reader.Err() != nil {
+			return nil, reader.Err()
+		}
+		return nil, validation.Errors{"file": ErrFileEmpty}
+	}
+	columns, err := mapColumns(reader.Row(), mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	curdImport := model.CurdImport{
+		Id:        randomHex(16),
+		TenantId:  ctxutil.GetTenantFromCtx(ctx),
+		CreatedBy: ctxutil.GetUserFromCtx(ctx),
+		FileName:  fileName,
+		FilePath:  path,
+		DryRun:    dryRun,
+		Status:    constant.ImportStatusPending,
+	}
+	err = s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.CurdImportDao.Create(ctx, &curdImport); err != nil {
+			return err
+		}
+		// never retried, the rows imported by the failed attempt would be imported again
+		payload := curdImportJob{ImportId: curdImport.Id, Columns: columns}
+		_, err := s.JobManager.Enqueue(ctx, constant.ImportJobType, payload, job.MaxAttempts(1))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return importDTOOf(&curdImport), nil
+}
+
+// Get returns the import of the user of ctx.
+func (s *CurdImportService) Get(ctx context.Context, id string) (*response.ImportJobDTO, error) {
+	curdImport, err := s.CurdImportDao.FindOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if curdImport == nil {
+		return nil, ErrImportJobNotFound
+	}
+	return importDTOOf(curdImport), nil
+}
+
+// ReportPath returns the csv report of the rejected rows of a finished import of the
+// user of ctx.
+func (s *CurdImportService) ReportPath(ctx context.Context, id string) (string, error) {
+	curdImport, err := s.CurdImportDao.FindOwned(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if curdImport == nil || !curdImport.HasReport || curdImport.FinishedAt == nil {
+		return "", ErrImportJobNotFound
+	}
+	return curdImport.ReportPath, nil
+}
+
+// run is the handler of the curd.import jobs. A failed import fails its job too.
+func (s *CurdImportService) run(ctx context.Context, p curdImportJob) (err error) {
+	curdImport, err := s.CurdImportDao.FindByImportID(ctx, p.ImportId)
+	if err != nil {
+		return err
+	}
+	if curdImport == nil || curdImport.FinishedAt != nil {
+		log.Ctx(ctx).Info().Msgf("Skip import %v, it's deleted or finished", p.ImportId)
+		return nil
+	}
+	ctx = ctxutil.ContextWithTenant(ctx, curdImport.TenantId)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		s.finish(ctx, curdImport, err)
+	}()
+	if curdImport.Status != constant.ImportStatusPending {
+		return errImportInterrupted
+	}
+	curdImport.Status = constant.ImportStatusRunning
+	if _, err = s.CurdImportDao.Update(ctx, curdImport); err != nil {
+		return err
+	}
+	return s.process(ctx, curdImport, p.Columns)
+}
+
+// finish records the outcome of the import and removes its file.
+func (s *CurdImportService) finish(ctx context.Context, curdImport *model.CurdImport, err error) {
+	now := time.Now()
+	curdImport.FinishedAt = &now
+	curdImport.Status = constant.ImportStatusDone
+	if err != nil {
+		curdImport.Status = constant.ImportStatusFailed
+		curdImport.ErrorMessage = err.Error()
+	}
+	if _, dbErr := s.CurdImportDao.Update(ctx, curdImport); dbErr != nil {
+		log.Ctx(ctx).Error().Err(dbErr).Msgf("Save import %v failed", curdImport.Id)
+	}
+	if err := os.Remove(curdImport.FilePath); err != nil && !os.IsNotExist(err) {
+		log.Ctx(ctx).Error().Err(err).Msgf("Remove the file of import %v failed", curdImport.Id)
+	}
+}
+
+func (s *CurdImportService) process(ctx context.Context, curdImport *model.CurdImport, columns map[int]string) error {
+	format, err := sheetutil.FormatOf(curdImport.FileName)
+	if err != nil {
+		return err
+	}
+	reader, err := sheetutil.Open(curdImport.FilePath, format)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if !reader.Next() {
+		return reader.Err()
+	}
+	header := append([]string(nil), reader.Row()...)
+
+	curdImport.ReportPath = filepath.Join(s.Dir, curdImport.Id+"-report.csv")
+	report, err := os.Create(curdImport.ReportPath)
+	if err != nil {
+		return err
+	}
+	defer report.Close()
+	reportWriter := csv.NewWriter(report)
+	// write errors are kept by the csv writer and checked once at the end
+	_ = reportWriter.Write(append(append([]string{"row"}, header...), "errors"))
+
+	reject := func(row importRow, err error) {
+		_ = reportWriter.Write(append(append([]string{strconv.Itoa(row.number)}, row.values...), err.Error()))
+		curdImport.RejectedRows++
+		curdImport.HasReport = true
+	}
+	batch := make([]importRow, 0, constant.BulkBatchSize)
+	// the progress is saved after each batch
+	flush := func() {
+		imported, err := s.insert(ctx, batch, curdImport.DryRun, reject)
+		util.Must(err)
+		curdImport.ImportedRows += imported
+		batch = batch[:0]
+		_, err = s.CurdImportDao.Update(ctx, curdImport)
+		util.Must(err)
+	}
+
+	for number := 2; reader.Next(); number++ {
+		values := append([]string(nil), reader.Row()...)
+		if isBlankRow(values) {
+			continue
+		}
+		curdImport.TotalRows++
+		row := importRow{number: number, values: values}
+		dto := rowToCurdDTO(values, columns)
+		if err := dto.Validate(); err != nil {
+			reject(row, err)
+			continue
+		}
+		row.dto = dto
+		batch = append(batch, row)
+		if len(batch) == constant.BulkBatchSize {
+			flush()
+		}
+	}
+	flush()
+	reportWriter.Flush()
+	if err := reportWriter.Error(); err != nil {
+		return err
+	}
+	return reader.Err()
+}
+
+// insert checks the rules of CurdValidator on a batch, writes its valid rows and
+// returns the number of imported rows. When the batch is rejected by the database the
+// rows are retried one by one to find the faulty ones. The curd.created events of the
+// imported rows are published with them.
+func (s *CurdImportService) insert(ctx context.Context, batch []importRow, dryRun bool, reject func(importRow, error)) (int, error) {
+	dtos := make([]*request.CurdDTO, len(batch))
+	for i := range batch {
+		dtos[i] = &batch[i].dto
+	}
+	errs, err := s.CurdValidator.ValidateAll(ctx, dtos, make([]uint, len(dtos)))
+	if err != nil {
+		return 0, err
+	}
+	tenantId := ctxutil.GetTenantFromCtx(ctx)
+	rows := make([]importRow, 0, len(batch))
+	curds := make([]model.Curd, 0, len(batch))
+	for i, row := range batch {
+		if errs[i] != nil {
+			reject(row, errs[i])
+			continue
+		}
+		curd := model.Curd{TenantId: tenantId}
+		util.Must(copier.Copy(&curd, &row.dto))
+		rows = append(rows, row)
+		curds = append(curds, curd)
+	}
+	if dryRun || len(curds) == 0 {
+		return len(curds), nil
+	}
+	// the imported rows only add to the lists, the cached curds stay valid
+	defer func() {
+		if err := s.CachedCurdDao.Invalidate(ctx); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Can't invalidate the cached curds")
+		}
+	}()
+	err = s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		created, err := s.CurdDao.CreateInBatches(ctx, append([]model.Curd(nil), curds...), constant.BulkBatchSize)
+		if err != nil {
+			return err
+		}
+		return s.WebhookService.Publish(ctx, constant.WebhookEventCurdCreated, curdEvents(created)...)
+	})
+	if err == nil {
+		return len(curds), nil
+	}
+	imported := 0
+	for i := range curds {
+		err := s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := s.CurdDao.Create(ctx, &curds[i]); err != nil {
+				return emailTakenError(err)
+			}
+			return s.WebhookService.Publish(ctx, constant.WebhookEventCurdCreated, curdEvents(curds[i:i+1])...)
+		})
+		if err != nil {
+			reject(rows[i], err)
+			continue
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+func importDTOOf(curdImport *model.CurdImport) *response.ImportJobDTO {
+	var dto response.ImportJobDTO
+	util.Must(copier.Copy(&dto, curdImport))
+	return &dto
+}
+
+// mapColumns returns the curd field of each mapped column index.
+func mapColumns(header []string, mapping map[string]string) (map[int]string, error) {
+	normalized := make(map[string]string, len(mapping))
+	for column, field := range mapping {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !isCurdImportField(field) {
+			return nil, validation.Errors{"mapping": ErrMappingUnknownField.SetParams(map[string]interface{}{"column": column, "field": field})}
+		}
+		normalized[strings.ToLower(strings.TrimSpace(column))] = field
+	}
+
+	columns := make(map[int]string)
+	mapped := make(map[string]bool)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		field, ok := normalized[column]
+		if !ok && isCurdImportField(column) {
+			field, ok = column, true
+		}
+		if !ok {
+			continue
+		}
+		if mapped[field] {
+			return nil, validation.Errors{"mapping": ErrMappingDuplicateField.SetParams(map[string]interface{}{"field": field})}
+		}
+		mapped[field] = true
+		columns[i] = field
+	}
+	if !mapped["name"] {
+		return nil, validation.Errors{"mapping": ErrMappingNameMissing}
+	}
+	return columns, nil
+}
+
+func rowToCurdDTO(values []string, columns map[int]string) request.CurdDTO {
+	var dto request.CurdDTO
+	for i, field := range columns {
+		if i >= len(values) {
+			continue
+		}
+		value := strings.TrimSpace(values[i])
+		switch field {
+		case "name":
+			dto.Name = value
+		case "email":
+			dto.Email = value
+		case "phone":
+			dto.Phone = value
+		case "city":
+			dto.City = value
+		}
+	}
+	return dto
+}
+
+func isCurdImportField(field string) bool {
+	for _, f := range curdImportFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlankRow(values []string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"demo-curd/dao"
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/textutil"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/jinzhu/copier"
+)
+
+// ErrSearchQueryRequired is the error of a query without any word, answered with 400.
+var ErrSearchQueryRequired = validation.Errors{"q": validation.ErrRequired}
+
+type CurdSearchService struct {
+	CurdSearcher dao.CurdSearcher
+}
+
+// Search returns the curds matching every word of query, ignoring case and
+// diacritics, best matches first.
+func (s *CurdSearchService) Search(ctx context.Context, query string, page request.Page) (*response.PageDTO, error) {
+	terms := textutil.Terms(query)
+	if len(terms) == 0 {
+		return nil, ErrSearchQueryRequired
+	}
+	if len(terms) > constant.SearchMaxTerms {
+		terms = terms[:constant.SearchMaxTerms]
+	}
+	curds, total, err := s.CurdSearcher.Search(ctx, terms, (page.Page-1)*page.Size, page.Size)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]response.CurdSearchHitDTO, len(curds))
+	for i := range curds {
+		util.Must(copier.Copy(&items[i].CurdDTO, &curds[i]))
+		items[i].Highlights = make(map[string]string)
+		for field, value := range map[string]string{
+			"name":  curds[i].Name,
+			"email": curds[i].Email,
+			"phone": curds[i].Phone,
+			"city":  curds[i].City,
+		} {
+			if highlight := textutil.Highlight(value, terms); highlight != "" {
+				items[i].Highlights[field] = highlight
+			}
+		}
+	}
+	return &response.PageDTO{
+		Items: items,
+		Page:  page.Page,
+		Size:  page.Size,
+		Total: total,
+	}, nil
+}
@@ -1,27 +1,177 @@
 package service
 
 import (
+	"context"
 	"demo-curd/dao"
 	"demo-curd/dto/request"
 	"demo-curd/dto/response"
 	"demo-curd/model"
 	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"demo-curd/util/dbutil"
+	"fmt"
 	"github.com/jinzhu/copier"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
+// columns the list and export APIs can be sorted by
+var curdSortColumns = []string{"id", "name", "email", "phone", "city", "created_at", "updated_at"}
+
 type CurdService struct {
-	CurdDao *dao.CurdDao
+	CurdDao        *dao.CurdDao
+	CachedCurdDao  *dao.CachedCurdDao
+	TxManager      *TxManager
+	WebhookService *WebhookService
+	CurdValidator  *CurdValidator
 }
 
-func (s *CurdService) Create(dto *request.CurdDTO) (*response.CurdDTO, error) {
+// Get reads the curd through the cache.
+func (s *CurdService) Get(ctx context.Context, id uint64) (*response.CurdDTO, error) {
+	curd, err := s.CachedCurdDao.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if curd == nil {
+		return nil, errNotFound
+	}
+	var res response.CurdDTO
+	util.Must(copier.Copy(&res, curd))
+	return &res, nil
+}
+
+func (s *CurdService) Create(ctx context.Context, dto *request.CurdDTO) (*response.CurdDTO, error) {
 	var curd model.Curd
 	if err1 := dto.Validate(); err1 != nil {
 		return nil, err1
 	}
+	if err1 := s.CurdValidator.Validate(ctx, dto, 0); err1 != nil {
+		return nil, err1
+	}
 	util.Must(copier.Copy(&curd, &dto))
-	_, err1 := s.CurdDao.Create(&curd)
+	curd.TenantId = ctxutil.GetTenantFromCtx(ctx)
+	var res response.CurdDTO
+	err1 := s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.CurdDao.Create(ctx, &curd); err != nil {
+			return emailTakenError(err)
+		}
+		util.Must(copier.Copy(&res, &curd))
+		return s.WebhookService.Publish(ctx, constant.WebhookEventCurdCreated, curdEvents([]model.Curd{curd})...)
+	})
 	util.Must(err1)
+	s.invalidate(ctx, uint64(curd.Id))
+	return &res, nil
+}
+
+// Patch applies a JSON Merge Patch or a JSON Patch, see request.ApplyPatch, to the
+// curd with the given id. The result is validated like a new curd and only the changed
+// columns are written.
+func (s *CurdService) Patch(ctx context.Context, id uint64, contentType string, patch []byte) (*response.CurdDTO, error) {
 	var res response.CurdDTO
-	util.Must(copier.Copy(&res, &curd))
+	err := s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		curd, err := s.CurdDao.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if curd == nil {
+			return errNotFound
+		}
+		var original, dto request.CurdDTO
+		util.Must(copier.Copy(&original, curd))
+		if err = request.ApplyPatch(contentType, patch, original, &dto); err != nil {
+			return err
+		}
+		if err = dto.Validate(); err != nil {
+			return err
+		}
+		if err = s.CurdValidator.Validate(ctx, &dto, curd.Id); err != nil {
+			return err
+		}
+
+		columns := changedCurdColumns(original, dto)
+		util.Must(copier.Copy(&res, curd))
+		if len(columns) == 0 {
+			return nil
+		}
+		// the BeforeSave hook doesn't run on map updates
+		columns["search_text"] = model.CurdSearchText(dto.Name, dto.Email, dto.Phone, dto.City)
+		if _, err = s.CurdDao.PartialUpdate(ctx, id, columns); err != nil {
+			return emailTakenError(err)
+		}
+		if curd, err = s.CurdDao.FindByID(ctx, id); err != nil {
+			return err
+		}
+		util.Must(copier.Copy(&res, curd))
+		return s.WebhookService.Publish(ctx, constant.WebhookEventCurdUpdated, curdEvents([]model.Curd{*curd})...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, id)
 	return &res, nil
 }
+
+func changedCurdColumns(original request.CurdDTO, patched request.CurdDTO) map[string]interface{} {
+	columns := make(map[string]interface{})
+	if patched.Name != original.Name {
+		columns["name"] = patched.Name
+	}
+	if patched.Email != original.Email {
+		columns["email"] = patched.Email
+	}
+	if patched.Phone != original.Phone {
+		columns["phone"] = patched.Phone
+	}
+	if patched.City != original.City {
+		columns["city"] = patched.City
+	}
+	return columns
+}
+
+func (s *CurdService) List(ctx context.Context, filter request.CurdFilterDTO, page request.Page) (*response.PageDTO, error) {
+	// Pagination orders by page.Sort as is, so it's checked against the allowed columns first
+	if _, err := dbutil.ParseSort(page.Sort, curdSortColumns...); err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("%#v %#v", filter, page)
+	curds, total, err := s.CachedCurdDao.ListPage(ctx, query, curdFilter(filter), dbutil.Pagination(page))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]response.CurdDTO, 0, len(curds))
+	util.Must(copier.Copy(&items, &curds))
+	return &response.PageDTO{
+		Items: items,
+		Page:  page.Page,
+		Size:  page.Size,
+		Total: total,
+	}, nil
+}
+
+func curdFilter(filter request.CurdFilterDTO) dao.Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(
+			dbutil.Contains(map[string]string{"name": filter.Name, "email": filter.Email, "phone": filter.Phone}),
+			dbutil.Equal(map[string]interface{}{"city": filter.City}))
+	}
+}
+
+// invalidate forgets the cached curds of ids and pages once their changes are
+// committed. A failure only delays the changes to the readers until the cache ttl.
+func (s *CurdService) invalidate(ctx context.Context, ids ...uint64) {
+	if err := s.CachedCurdDao.Invalidate(ctx, ids...); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Can't invalidate the cached curds")
+	}
+}
+
+// curdEvents converts curds to their webhook events, sent to the tenant of each curd.
+func curdEvents(curds []model.Curd) []WebhookEvent {
+	events := make([]WebhookEvent, len(curds))
+	for i := range curds {
+		var dto response.CurdDTO
+		util.Must(copier.Copy(&dto, &curds[i]))
+		events[i] = WebhookEvent{TenantId: curds[i].TenantId, Data: dto}
+	}
+	return events
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"demo-curd/config"
+	"demo-curd/dao"
+	"demo-curd/dto/request"
+	"demo-curd/util/ctxutil"
+	"demo-curd/util/dbutil"
+	"demo-curd/util/textutil"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/nyaruka/phonenumbers"
+	"strings"
+)
+
+var (
+	ErrPhoneInvalid   = validation.NewError("validation_phone_invalid", "must be a valid phone number")
+	ErrCityInvalid    = validation.NewError("validation_city_invalid", "must be a supported city")
+	ErrEmailTaken     = validation.NewError("validation_email_taken", "is already used by another record")
+	ErrEmailDuplicate = validation.NewError("validation_email_duplicate", "is used by another item of the request")
+)
+
+// CurdValidator checks the rules of a curd depending on the configuration or the
+// database, on dtos already valid according to request.CurdDTO.Validate. It also
+// normalizes the dtos: emails are lower cased, phones formatted in E.164 and cities
+// spelled as in the reference list.
+type CurdValidator struct {
+	CurdDao *dao.CurdDao
+	region  string
+	// reference spelling by normalized city
+	cities map[string]string
+}
+
+func NewCurdValidator(c config.Config, curdDao *dao.CurdDao) *CurdValidator {
+	cities := make(map[string]string, len(c.Validation.Cities))
+	for _, city := range c.Validation.Cities {
+		cities[textutil.Normalize(city)] = strings.TrimSpace(city)
+	}
+	return &CurdValidator{
+		CurdDao: curdDao,
+		region:  c.Validation.PhoneRegion,
+		cities:  cities,
+	}
+}
+
+// Validate checks a single dto, id is the curd being updated or 0 on creation.
+func (v *CurdValidator) Validate(ctx context.Context, dto *request.CurdDTO, id uint) error {
+	errs, err := v.ValidateAll(ctx, []*request.CurdDTO{dto}, []uint{id})
+	if err != nil {
+		return err
+	}
+	return errs[0]
+}
+
+// ValidateAll checks dtos at once, ids are the curds being updated or 0 on creation.
+// It returns the validation error of every dto, nil when valid, and fails only when
+// the database can't be queried.
+func (v *CurdValidator) ValidateAll(ctx context.Context, dtos []*request.CurdDTO, ids []uint) ([]error, error) {
+	fieldErrs := make([]validation.Errors, len(dtos))
+	byEmail := make(map[string][]int)
+	for i, dto := range dtos {
+		fieldErrs[i] = validation.Errors{}
+		dto.Email = strings.ToLower(strings.TrimSpace(dto.Email))
+		if dto.Email != "" {
+			byEmail[dto.Email] = append(byEmail[dto.Email], i)
+		}
+		if err := v.normalizePhone(dto); err != nil {
+			fieldErrs[i]["phone"] = err
+		}
+		if err := v.normalizeCity(dto); err != nil {
+			fieldErrs[i]["city"] = err
+		}
+	}
+
+	emails := make([]string, 0, len(byEmail))
+	for email, indexes := range byEmail {
+		emails = append(emails, email)
+		if len(indexes) > 1 {
+			for _, i := range indexes {
+				fieldErrs[i]["email"] = ErrEmailDuplicate
+			}
+		}
+	}
+	existing, err := v.CurdDao.FindByEmails(ctx, ctxutil.GetTenantFromCtx(ctx), emails)
+	if err != nil {
+		return nil, err
+	}
+	for _, curd := range existing {
+		for _, i := range byEmail[strings.ToLower(curd.Email)] {
+			if curd.Id != ids[i] {
+				fieldErrs[i]["email"] = ErrEmailTaken
+			}
+		}
+	}
+
+	errs := make([]error, len(dtos))
+	for i := range fieldErrs {
+		errs[i] = fieldErrs[i].Filter()
+	}
+	return errs, nil
+}
+
+// emailTakenError maps the violation of the unique index on the emails of a tenant to
+// ErrEmailTaken, when another request took the email after the validation.
+func emailTakenError(err error) error {
+	if dbutil.IsDuplicateKey(err) {
+		return validation.Errors{"email": ErrEmailTaken}
+	}
+	return err
+}
+
+func (v *CurdValidator) normalizePhone(dto *request.CurdDTO) error {
+	if strings.TrimSpace(dto.Phone) == "" {
+		dto.Phone = ""
+		return nil
+	}
+	number, err := phonenumbers.Parse(dto.Phone, v.region)
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return ErrPhoneInvalid
+	}
+	dto.Phone = phonenumbers.Format(number, phonenumbers.E164)
+	return nil
+}
+
+func (v *CurdValidator) normalizeCity(dto *request.CurdDTO) error {
+	dto.City = strings.TrimSpace(dto.City)
+	if dto.City == "" || len(v.cities) == 0 {
+		return nil
+	}
+	city, ok := v.cities[textutil.Normalize(dto.City)]
+	if !ok {
+		return ErrCityInvalid
+	}
+	dto.City = city
+	return nil
+}
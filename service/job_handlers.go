@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"demo-curd/config"
+	"demo-curd/dao"
+	"demo-curd/job"
+	"demo-curd/util/constant"
+	"github.com/rs/zerolog/log"
+	"os"
+	"time"
+)
+
+// NewJobHandlers lists the handlers run by the job manager, add the handler of a new
+// job type here.
+func NewJobHandlers(c config.Config, jobDao *dao.JobDao, idempotencyKeyDao *dao.IdempotencyKeyDao, curdImportDao *dao.CurdImportDao,
+	webhookDeliveryService *WebhookDeliveryService) []job.Handler {
+	return []job.Handler{
+		job.NewCleanupHandler(jobDao, c.Job.Retention),
+		webhookDeliveryService.Handler(),
+		idempotencyCleanupHandler(idempotencyKeyDao),
+		curdImportCleanupHandler(curdImportDao),
+	}
+}
+
+// idempotencyCleanupHandler deletes the expired idempotency keys.
+func idempotencyCleanupHandler(idempotencyKeyDao *dao.IdempotencyKeyDao) job.Handler {
+	return job.HandlerFunc(constant.IdempotencyCleanupJobType, func(ctx context.Context, _ struct{}) error {
+		deleted, err := idempotencyKeyDao.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+		log.Ctx(ctx).Info().Msgf("Deleted %v expired idempotency keys", deleted)
+		return nil
+	})
+}
+
+// curdImportCleanupHandler deletes the imports finished for longer than the retention
+// along with their reports.
+func curdImportCleanupHandler(curdImportDao *dao.CurdImportDao) job.Handler {
+	return job.HandlerFunc(constant.ImportCleanupJobType, func(ctx context.Context, _ struct{}) error {
+		imports, err := curdImportDao.FinishedBefore(ctx, time.Now().Add(-constant.ImportJobRetention))
+		if err != nil {
+			return err
+		}
+		ids := make([]string, len(imports))
+		for i, curdImport := range imports {
+			ids[i] = curdImport.Id
+			if curdImport.ReportPath != "" {
+				if err := os.Remove(curdImport.ReportPath); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+		deleted, err := curdImportDao.DeleteByImportIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		log.Ctx(ctx).Info().Msgf("Deleted %v finished imports", deleted)
+		return nil
+	})
+}
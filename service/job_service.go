@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"demo-curd/dao"
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/i18n"
+	"demo-curd/job"
+	"demo-curd/model"
+	"demo-curd/util/dbutil"
+	"encoding/json"
+)
+
+// columns the job list can be sorted by
+var jobSortColumns = []string{"id", "type", "status", "run_at", "created_at", "updated_at"}
+
+var (
+	msgJobNotRetryable   = &i18n.Message{ID: "error.job_not_retryable", Other: "The job can't be retried in status {{.status}}"}
+	msgJobNotCancellable = &i18n.Message{ID: "error.job_not_cancellable", Other: "The job can't be cancelled in status {{.status}}"}
+)
+
+// JobService backs the admin API of the job queue.
+type JobService struct {
+	JobDao     *dao.JobDao
+	JobManager *job.Manager
+}
+
+func (s *JobService) List(ctx context.Context, filter request.JobFilterDTO, page request.Page) (*response.PageDTO, error) {
+	if _, err := dbutil.ParseSort(page.Sort, jobSortColumns...); err != nil {
+		return nil, err
+	}
+	scope := dbutil.Equal(map[string]interface{}{"type": filter.Type, "status": filter.Status})
+	total, err := s.JobDao.Count(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := s.JobDao.List(ctx, scope, dbutil.Pagination(page))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]response.JobDTO, 0, len(jobs))
+	for i := range jobs {
+		items = append(items, jobDTOOf(&jobs[i]))
+	}
+	return &response.PageDTO{
+		Items: items,
+		Page:  page.Page,
+		Size:  page.Size,
+		Total: total,
+	}, nil
+}
+
+func (s *JobService) Get(ctx context.Context, id uint64) (*response.JobDTO, error) {
+	j, err := s.JobDao.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if j == nil {
+		return nil, errNotFound
+	}
+	res := jobDTOOf(j)
+	return &res, nil
+}
+
+// Retry puts a failed or cancelled job back in the queue.
+func (s *JobService) Retry(ctx context.Context, id uint64) (*response.JobDTO, error) {
+	ok, err := s.JobDao.Retry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.unchanged(ctx, id, msgJobNotRetryable)
+	}
+	return s.Get(ctx, id)
+}
+
+// Cancel cancels a pending job, or a running one which is interrupted if it runs on
+// this instance. On other instances its result is discarded when it finishes.
+func (s *JobService) Cancel(ctx context.Context, id uint64) (*response.JobDTO, error) {
+	ok, err := s.JobDao.Cancel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.unchanged(ctx, id, msgJobNotCancellable)
+	}
+	s.JobManager.Interrupt(id)
+	return s.Get(ctx, id)
+}
+
+// unchanged returns the conflict of a job left unchanged by its status, or errNotFound.
+func (s *JobService) unchanged(ctx context.Context, id uint64, message *i18n.Message) error {
+	j, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return response.Conflict(i18n.NewError(message, map[string]interface{}{"status": j.Status}, nil))
+}
+
+func jobDTOOf(j *model.Job) response.JobDTO {
+	res := response.JobDTO{
+		Id:          j.Id,
+		Type:        j.Type,
+		Status:      j.Status,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		RunAt:       j.RunAt,
+		LockedBy:    j.LockedBy,
+		LastError:   j.LastError,
+		FinishedAt:  j.FinishedAt,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+	}
+	if j.Payload != "" {
+		res.Payload = json.RawMessage(j.Payload)
+	}
+	return res
+}
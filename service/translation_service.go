@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"demo-curd/dao"
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/i18n"
+	"demo-curd/model"
+	"demo-curd/util/dbutil"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrMessageIdTaken  = validation.NewError("validation_message_id_taken", "is already translated in this language")
+	ErrMessagesInvalid = validation.NewError("validation_messages_invalid", "must be a valid messages.<lang>.json file")
+)
+
+// TranslationService manages the messages edited by the admins. They replace the
+// messages of the files once written, on the other instances after i18n.refreshInterval.
+type TranslationService struct {
+	TxManager      *TxManager
+	TranslationDao *dao.TranslationDao
+	I18n           *i18n.I18n
+}
+
+func (s *TranslationService) List(ctx context.Context, filter request.TranslationFilterDTO, page request.Page) (*response.PageDTO, error) {
+	if _, err := dbutil.ParseSort(page.Sort, "id", "lang", "message_id", "created_at", "updated_at"); err != nil {
+		return nil, err
+	}
+	scope := dbutil.Equal(map[string]interface{}{"lang": filter.Lang, "message_id": filter.MessageId})
+	total, err := s.TranslationDao.Count(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	translations, err := s.TranslationDao.List(ctx, scope, dbutil.Pagination(page))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]response.TranslationDTO, 0, len(translations))
+	for i := range translations {
+		items = append(items, translationDTOOf(&translations[i]))
+	}
+	return &response.PageDTO{
+		Items: items,
+		Page:  page.Page,
+		Size:  page.Size,
+		Total: total,
+	}, nil
+}
+
+func (s *TranslationService) Get(ctx context.Context, id uint64) (*response.TranslationDTO, error) {
+	translation, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	res := translationDTOOf(translation)
+	return &res, nil
+}
+
+func (s *TranslationService) Create(ctx context.Context, dto *request.TranslationDTO) (*response.TranslationDTO, error) {
+	if err := s.validate(ctx, dto, 0); err != nil {
+		return nil, err
+	}
+	translation := model.Translation{
+		Lang:      dto.Lang,
+		MessageId: dto.MessageId,
+		One:       dto.One,
+		Other:     dto.Other,
+	}
+	if _, err := s.TranslationDao.Create(ctx, &translation); err != nil {
+		return nil, err
+	}
+	s.refresh(ctx)
+	res := translationDTOOf(&translation)
+	return &res, nil
+}
+
+func (s *TranslationService) Update(ctx context.Context, id uint64, dto *request.TranslationDTO) (*response.TranslationDTO, error) {
+	translation, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.validate(ctx, dto, id); err != nil {
+		return nil, err
+	}
+	translation.Lang = dto.Lang
+	translation.MessageId = dto.MessageId
+	translation.One = dto.One
+	translation.Other = dto.Other
+	if _, err = s.TranslationDao.Update(ctx, translation); err != nil {
+		return nil, err
+	}
+	s.refresh(ctx)
+	res := translationDTOOf(translation)
+	return &res, nil
+}
+
+// Delete brings the message of the files back.
+func (s *TranslationService) Delete(ctx context.Context, id uint64) error {
+	translation, err := s.find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err = s.TranslationDao.Delete(ctx, translation); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+// Export returns the messages of lang used by the app, the edited ones included, in
+// the format of the message files: the text, or its plural forms, by message id.
+func (s *TranslationService) Export(lang string) (map[string]interface{}, error) {
+	messages := s.I18n.Messages(lang)
+	if messages == nil {
+		return nil, validation.Errors{"lang": validation.ErrInInvalid}
+	}
+	res := make(map[string]interface{}, len(messages))
+	for id, message := range messages {
+		if message.One == "" {
+			res[id] = message.Other
+		} else {
+			res[id] = map[string]string{"one": message.One, "other": message.Other}
+		}
+	}
+	return res, nil
+}
+
+// Import saves the messages of a messages.<lang>.json file differing from the ones
+// used by the app, all of them or none.
+func (s *TranslationService) Import(ctx context.Context, lang string, data []byte) (*response.TranslationImportDTO, error) {
+	current := s.I18n.Messages(lang)
+	if current == nil {
+		return nil, validation.Errors{"lang": validation.ErrInInvalid}
+	}
+	messages, err := i18n.ParseMessages(lang, "json", data)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("Invalid message file")
+		return nil, validation.Errors{"file": ErrMessagesInvalid}
+	}
+	res := &response.TranslationImportDTO{}
+	err = s.TxManager.WithinTx(ctx, func(ctx context.Context) error {
+		for _, message := range messages {
+			if m, ok := current[message.ID]; ok && m.One == message.One && m.Other == message.Other {
+				res.Unchanged++
+				continue
+			}
+			dto := request.TranslationDTO{Lang: lang, MessageId: message.ID, One: message.One, Other: message.Other}
+			if err := dto.Validate(); err != nil {
+				return validation.Errors{message.ID: err}
+			}
+			translation, err := s.TranslationDao.FindByMessageId(ctx, lang, message.ID)
+			if err != nil {
+				return err
+			}
+			if translation == nil {
+				res.Created++
+				translation = &model.Translation{Lang: lang, MessageId: message.ID}
+			} else {
+				res.Updated++
+			}
+			translation.One, translation.Other = message.One, message.Other
+			if _, err = s.TranslationDao.Update(ctx, translation); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.refresh(ctx)
+	return res, nil
+}
+
+// validate checks dto for the translation id, 0 for a new one.
+func (s *TranslationService) validate(ctx context.Context, dto *request.TranslationDTO, id uint64) error {
+	if err := dto.Validate(); err != nil {
+		return err
+	}
+	if s.I18n.Messages(dto.Lang) == nil {
+		return validation.Errors{"lang": validation.ErrInInvalid}
+	}
+	existing, err := s.TranslationDao.FindByMessageId(ctx, dto.Lang, dto.MessageId)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Id != id {
+		return validation.Errors{"message_id": ErrMessageIdTaken}
+	}
+	return nil
+}
+
+func (s *TranslationService) find(ctx context.Context, id uint64) (*model.Translation, error) {
+	translation, err := s.TranslationDao.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if translation == nil {
+		return nil, errNotFound
+	}
+	return translation, nil
+}
+
+// refresh applies the written messages, a failure leaves the current ones until the
+// next refresh.
+func (s *TranslationService) refresh(ctx context.Context) {
+	if err := s.I18n.Refresh(ctx); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Can't refresh the messages")
+	}
+}
+
+func translationDTOOf(t *model.Translation) response.TranslationDTO {
+	return response.TranslationDTO{
+		Id:        t.Id,
+		Lang:      t.Lang,
+		MessageId: t.MessageId,
+		One:       t.One,
+		Other:     t.Other,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+	}
+}
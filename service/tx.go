@@ -0,0 +1,21 @@
+package service
+
+import (
+	"context"
+	"demo-curd/database"
+	"gorm.io/gorm"
+)
+
+type TxManager struct {
+	Db *database.Database
+}
+
+// WithinTx runs fn in a transaction carried by the ctx passed to fn, so every DAO call
+// made with that ctx joins it. It commits when fn returns nil and rolls back when fn
+// returns an error or panics. Nested calls create a savepoint inside the outer
+// transaction and only roll back to it.
+func (s *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.Db.Conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(database.ContextWithTx(ctx, s.Db, tx))
+	})
+}
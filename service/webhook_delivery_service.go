@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"demo-curd/config"
+	"demo-curd/dao"
+	"demo-curd/dto/response"
+	"demo-curd/job"
+	"demo-curd/model"
+	"demo-curd/util/constant"
+	"demo-curd/util/dbutil"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// WebhookDeliveryService posts the events to the subscriptions, it runs the
+// webhook.deliver jobs.
+type WebhookDeliveryService struct {
+	WebhookSubscriptionDao *dao.WebhookSubscriptionDao
+	WebhookDeliveryDao     *dao.WebhookDeliveryDao
+	Client                 *http.Client
+	options                webhookOptions
+}
+
+func NewWebhookDeliveryService(c config.Config, subscriptionDao *dao.WebhookSubscriptionDao, deliveryDao *dao.WebhookDeliveryDao) *WebhookDeliveryService {
+	options := webhookOptionsOf(c)
+	return &WebhookDeliveryService{
+		WebhookSubscriptionDao: subscriptionDao,
+		WebhookDeliveryDao:     deliveryDao,
+		Client:                 newWebhookClient(options),
+		options:                options,
+	}
+}
+
+// ErrAddressNotAllowed is the error of the deliveries to a loopback, private or
+// link-local address, e.g. a cloud metadata endpoint, see webhook.allowPrivateNetworks.
+var ErrAddressNotAllowed = errors.New("address not allowed")
+
+// newWebhookClient returns a client that doesn't follow redirects, a 3xx is a failed
+// delivery, and checks the address it connects to after the DNS resolution, so a
+// host resolving to a private address is refused as well.
+func newWebhookClient(options webhookOptions) *http.Client {
+	dialer := &net.Dialer{Timeout: options.Timeout}
+	if !options.AllowPrivateNetworks {
+		dialer.Control = func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %v", ErrAddressNotAllowed, host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: options.Timeout,
+		// no proxy, it would be the address checked
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: options.Timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+func (s *WebhookDeliveryService) Handler() job.Handler {
+	return job.HandlerFunc(constant.WebhookDeliverJobType, s.deliver)
+}
+
+// deliver posts the event and logs the attempt. A failure is returned to be retried
+// by the job queue, unless it disabled the subscription.
+func (s *WebhookDeliveryService) deliver(ctx context.Context, p webhookDeliveryJob) error {
+	subscription, err := s.WebhookSubscriptionDao.FindByID(ctx, p.SubscriptionId)
+	if err != nil {
+		return err
+	}
+	if subscription == nil || !subscription.Active {
+		log.Ctx(ctx).Info().Msgf("Skip event %v, webhook subscription %v is deleted or disabled", p.Event.Id, p.SubscriptionId)
+		return nil
+	}
+	attempts, err := s.WebhookDeliveryDao.Count(ctx, dbutil.Equal(map[string]interface{}{
+		"subscription_id": subscription.Id,
+		"event_id":        p.Event.Id,
+	}))
+	if err != nil {
+		return err
+	}
+
+	delivery := model.WebhookDelivery{
+		SubscriptionId: subscription.Id,
+		EventId:        p.Event.Id,
+		EventType:      p.Event.Type,
+		Attempt:        int(attempts) + 1,
+	}
+	start := time.Now()
+	delivery.StatusCode, err = s.post(ctx, subscription, p.Event)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	delivery.Success = err == nil
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if _, dbErr := s.WebhookDeliveryDao.Create(ctx, &delivery); dbErr != nil {
+		log.Ctx(ctx).Error().Err(dbErr).Msgf("Log delivery of event %v failed", p.Event.Id)
+	}
+
+	if err == nil {
+		return s.WebhookSubscriptionDao.RecordSuccess(ctx, subscription.Id)
+	}
+	disabled, dbErr := s.WebhookSubscriptionDao.RecordFailure(ctx, subscription.Id, s.options.DisableAfter)
+	if dbErr != nil {
+		log.Ctx(ctx).Error().Err(dbErr).Msgf("Count failure of webhook subscription %v failed", subscription.Id)
+	}
+	if disabled {
+		log.Ctx(ctx).Warn().Msgf("Webhook subscription %v disabled after %v failed deliveries", subscription.Id, s.options.DisableAfter)
+		return nil
+	}
+	return err
+}
+
+// post returns the status code of the response, any status outside 2xx is an error.
+func (s *WebhookDeliveryService) post(ctx context.Context, subscription *model.WebhookSubscription, event response.WebhookEventDTO) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(constant.WebhookHeaderId, event.Id)
+	req.Header.Set(constant.WebhookHeaderEvent, event.Type)
+	req.Header.Set(constant.WebhookHeaderSignature, Sign(subscription.Secret, time.Now(), body))
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %v", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header of a delivery, "t=<unix time>,v1=<hex hmac>" where
+// the HMAC-SHA256 of "<unix time>.<body>" is keyed with the subscription secret.
+// Receivers should recompute it and reject old timestamps to prevent replays.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%v,v1=%v", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"demo-curd/config"
+	"demo-curd/dao"
+	"demo-curd/dto/request"
+	"demo-curd/dto/response"
+	"demo-curd/job"
+	"demo-curd/model"
+	"demo-curd/util"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"demo-curd/util/dbutil"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// WebhookService manages the webhook subscriptions and publishes the events, each
+// delivery is a job run by WebhookDeliveryService.
+type WebhookService struct {
+	WebhookSubscriptionDao *dao.WebhookSubscriptionDao
+	WebhookDeliveryDao     *dao.WebhookDeliveryDao
+	JobManager             *job.Manager
+	options                webhookOptions
+}
+
+type webhookOptions struct {
+	Timeout              time.Duration
+	MaxAttempts          int
+	DisableAfter         int
+	AllowPrivateNetworks bool
+}
+
+// payload of the webhook.deliver jobs
+type webhookDeliveryJob struct {
+	SubscriptionId uint64                   `json:"subscription_id"`
+	Event          response.WebhookEventDTO `json:"event"`
+}
+
+func NewWebhookService(c config.Config, subscriptionDao *dao.WebhookSubscriptionDao, deliveryDao *dao.WebhookDeliveryDao, jobManager *job.Manager) *WebhookService {
+	return &WebhookService{
+		WebhookSubscriptionDao: subscriptionDao,
+		WebhookDeliveryDao:     deliveryDao,
+		JobManager:             jobManager,
+		options:                webhookOptionsOf(c),
+	}
+}
+
+// WebhookEvent is the data of an event and the tenant it belongs to, only the
+// subscribers of that tenant receive it.
+type WebhookEvent struct {
+	TenantId string
+	Data     interface{}
+}
+
+// Publish enqueues a delivery of eventType for every event to each matching
+// subscription of the tenant of the event. The jobs join the transaction of ctx, so
+// events of rolled back changes are never sent.
+func (s *WebhookService) Publish(ctx context.Context, eventType string, events ...WebhookEvent) error {
+	subscriptionsOf := make(map[string][]model.WebhookSubscription)
+	for _, item := range events {
+		subscriptions, ok := subscriptionsOf[item.TenantId]
+		if !ok {
+			var err error
+			if subscriptions, err = s.WebhookSubscriptionDao.FindActive(ctx, item.TenantId, eventType); err != nil {
+				return err
+			}
+			subscriptionsOf[item.TenantId] = subscriptions
+		}
+		if len(subscriptions) == 0 {
+			continue
+		}
+		b, err := json.Marshal(item.Data)
+		if err != nil {
+			return err
+		}
+		event := response.WebhookEventDTO{
+			Id:        randomHex(16),
+			Type:      eventType,
+			TenantId:  item.TenantId,
+			CreatedAt: time.Now(),
+			Data:      b,
+		}
+		for _, subscription := range subscriptions {
+			payload := webhookDeliveryJob{SubscriptionId: subscription.Id, Event: event}
+			if _, err := s.JobManager.Enqueue(ctx, constant.WebhookDeliverJobType, payload, job.MaxAttempts(s.options.MaxAttempts)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Create subscribes to the events of the tenant of ctx, or of every tenant for the
+// admins without tenant.
+func (s *WebhookService) Create(ctx context.Context, dto *request.WebhookSubscriptionDTO) (*response.WebhookSubscriptionDTO, error) {
+	tenantId, err := s.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := dto.Validate(); err != nil {
+		return nil, err
+	}
+	subscription := model.WebhookSubscription{
+		TenantId:   tenantId,
+		Url:        dto.Url,
+		Secret:     dto.Secret,
+		EventTypes: strings.Join(dto.EventTypes, ","),
+		Active:     dto.Active == nil || *dto.Active,
+	}
+	if subscription.Secret == "" {
+		subscription.Secret = randomHex(32)
+	}
+	if _, err := s.WebhookSubscriptionDao.Create(ctx, &subscription); err != nil {
+		return nil, err
+	}
+	res := webhookSubscriptionDTOOf(&subscription)
+	res.Secret = subscription.Secret
+	return &res, nil
+}
+
+func (s *WebhookService) List(ctx context.Context, page request.Page) (*response.PageDTO, error) {
+	tenantId, err := s.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dbutil.ParseSort(page.Sort, "id", "url", "created_at", "updated_at"); err != nil {
+		return nil, err
+	}
+	scope := dbutil.Equal(map[string]interface{}{"tenant_id": tenantId})
+	total, err := s.WebhookSubscriptionDao.Count(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := s.WebhookSubscriptionDao.List(ctx, scope, dbutil.Pagination(page))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]response.WebhookSubscriptionDTO, 0, len(subscriptions))
+	for i := range subscriptions {
+		items = append(items, webhookSubscriptionDTOOf(&subscriptions[i]))
+	}
+	return &response.PageDTO{
+		Items: items,
+		Page:  page.Page,
+		Size:  page.Size,
+		Total: total,
+	}, nil
+}
+
+func (s *WebhookService) Get(ctx context.Context, id uint64) (*response.WebhookSubscriptionDTO, error) {
+	subscription, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	res := webhookSubscriptionDTOOf(subscription)
+	return &res, nil
+}
+
+// Update replaces the subscription, the secret is kept when empty. Activating a
+// disabled subscription resets its failures.
+func (s *WebhookService) Update(ctx context.Context, id uint64, dto *request.WebhookSubscriptionDTO) (*response.WebhookSubscriptionDTO, error) {
+	if err := dto.Validate(); err != nil {
+		return nil, err
+	}
+	subscription, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Url = dto.Url
+	subscription.EventTypes = strings.Join(dto.EventTypes, ",")
+	if dto.Secret != "" {
+		subscription.Secret = dto.Secret
+	}
+	if dto.Active != nil && *dto.Active != subscription.Active {
+		subscription.Active = *dto.Active
+		subscription.FailureCount = 0
+		subscription.DisabledAt = nil
+		if !subscription.Active {
+			now := time.Now()
+			subscription.DisabledAt = &now
+		}
+	}
+	if _, err := s.WebhookSubscriptionDao.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+	res := webhookSubscriptionDTOOf(subscription)
+	return &res, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id uint64) error {
+	subscription, err := s.find(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.WebhookSubscriptionDao.Delete(ctx, subscription)
+	return err
+}
+
+// Deliveries returns the delivery logs of a subscription, latest first.
+func (s *WebhookService) Deliveries(ctx context.Context, id uint64, page request.Page) (*response.PageDTO, error) {
+	if _, err := s.find(ctx, id); err != nil {
+		return nil, err
+	}
+	page.Sort = "id desc"
+	scope := dbutil.Equal(map[string]interface{}{"subscription_id": id})
+	total, err := s.WebhookDeliveryDao.Count(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := s.WebhookDeliveryDao.List(ctx, scope, dbutil.Pagination(page))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]response.WebhookDeliveryDTO, 0, len(deliveries))
+	for _, d := range deliveries {
+		items = append(items, response.WebhookDeliveryDTO{
+			Id:         d.Id,
+			EventId:    d.EventId,
+			EventType:  d.EventType,
+			Attempt:    d.Attempt,
+			StatusCode: d.StatusCode,
+			Success:    d.Success,
+			Error:      d.Error,
+			DurationMs: d.DurationMs,
+			CreatedAt:  d.CreatedAt,
+		})
+	}
+	return &response.PageDTO{
+		Items: items,
+		Page:  page.Page,
+		Size:  page.Size,
+		Total: total,
+	}, nil
+}
+
+// tenant returns the tenant of ctx. The requests without tenant manage the
+// subscriptions of every tenant and the global ones, receiving the events of all the
+// tenants, so they must come from an admin.
+func (s *WebhookService) tenant(ctx context.Context) (string, error) {
+	tenantId := ctxutil.GetTenantFromCtx(ctx)
+	if tenantId == "" && !ctxutil.HasAuthority(ctx, constant.RoleAdmin) {
+		return "", response.ErrForbidden
+	}
+	return tenantId, nil
+}
+
+// find returns the subscription if it belongs to the tenant of ctx, admins without
+// tenant see every subscription.
+func (s *WebhookService) find(ctx context.Context, id uint64) (*model.WebhookSubscription, error) {
+	tenantId, err := s.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.WebhookSubscriptionDao.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil || (tenantId != "" && subscription.TenantId != tenantId) {
+		return nil, errNotFound
+	}
+	return subscription, nil
+}
+
+func webhookOptionsOf(c config.Config) webhookOptions {
+	return webhookOptions{
+		Timeout:              c.Webhook.Timeout,
+		MaxAttempts:          c.Webhook.MaxAttempts,
+		DisableAfter:         c.Webhook.DisableAfter,
+		AllowPrivateNetworks: c.Webhook.AllowPrivateNetworks,
+	}
+}
+
+func webhookSubscriptionDTOOf(s *model.WebhookSubscription) response.WebhookSubscriptionDTO {
+	return response.WebhookSubscriptionDTO{
+		Id:           s.Id,
+		TenantId:     s.TenantId,
+		Url:          s.Url,
+		EventTypes:   strings.Split(s.EventTypes, ","),
+		Active:       s.Active,
+		FailureCount: s.FailureCount,
+		DisabledAt:   s.DisabledAt,
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	util.Must(err)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"demo-curd/util"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AMQPHeadersCarrier carries the trace context in the headers of an AMQP message.
+type AMQPHeadersCarrier amqp.Table
+
+func (c AMQPHeadersCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c AMQPHeadersCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c AMQPHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// StartPublish starts the producer span of msg and injects its context in the headers
+// of msg, the caller publishes msg then ends the span, recording the error of the
+// publication with EndSpan.
+func StartPublish(ctx context.Context, exchange string, routingKey string, msg *amqp.Publishing) (context.Context, trace.Span) {
+	ctx, span := tracer().Start(ctx, exchange+" publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("rabbitmq"),
+			semconv.MessagingDestinationName(exchange),
+			semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+			semconv.MessagingOperationPublish,
+		))
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, AMQPHeadersCarrier(msg.Headers))
+	return ctx, span
+}
+
+// Consume wraps handle to run it in a consumer span of queue, child of the span of
+// the producer when the message headers carry its context.
+func Consume(queue string, handle func(ctx context.Context, delivery amqp.Delivery)) util.RabbitMQMsgHandleFunc {
+	return func(delivery amqp.Delivery) {
+		headers := delivery.Headers
+		if headers == nil {
+			headers = amqp.Table{}
+		}
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), AMQPHeadersCarrier(headers))
+		ctx, span := tracer().Start(ctx, queue+" process", trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystem("rabbitmq"),
+				semconv.MessagingSourceName(queue),
+				semconv.MessagingRabbitmqDestinationRoutingKey(delivery.RoutingKey),
+				semconv.MessagingOperationProcess,
+			))
+		defer span.End()
+		handle(ctx, delivery)
+	}
+}
+
+// EndSpan ends span, recording err when not nil.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// the AMQP helpers have no Tracing at hand, they use the global provider it sets
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+var _ propagation.TextMapCarrier = AMQPHeadersCarrier{}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"demo-curd/config"
+	"demo-curd/util/constant"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// name of the tracers of the application
+const instrumentationName = "demo-curd"
+
+// Tracing owns the tracer provider of the application, it's also set as the global
+// provider of OpenTelemetry along with the W3C trace context propagator.
+type Tracing struct {
+	Provider trace.TracerProvider
+	shutdown func(ctx context.Context) error
+}
+
+// NewTracing exports the spans with the exporter of the tracing config, nothing is
+// recorded when tracing is disabled.
+func NewTracing(c config.Config) (*Tracing, error) {
+	if !c.Tracing.Enabled {
+		return newTracing(trace.NewNoopTracerProvider(), func(context.Context) error { return nil }), nil
+	}
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch c.Tracing.Exporter {
+	case constant.TracingExporterOTLP:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(c.Tracing.Endpoint)}
+		if c.Tracing.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(context.Background(), opts...)
+	case constant.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		err = fmt.Errorf("tracing.exporter: unsupported exporter %v", c.Tracing.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return WithProcessor(c, sdktrace.NewBatchSpanProcessor(exporter)), nil
+}
+
+// WithProcessor records the spans with processor, e.g. the span recorder of
+// go.opentelemetry.io/otel/sdk/trace/tracetest to check them in tests.
+func WithProcessor(c config.Config, processor sdktrace.SpanProcessor) *Tracing {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.Tracing.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(c.Tracing.ServiceName),
+			semconv.DeploymentEnvironment(c.Env),
+		)),
+	)
+	return newTracing(provider, provider.Shutdown)
+}
+
+func newTracing(provider trace.TracerProvider, shutdown func(ctx context.Context) error) *Tracing {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return &Tracing{
+		Provider: provider,
+		shutdown: shutdown,
+	}
+}
+
+// Shutdown exports the pending spans.
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	return t.shutdown(ctx)
+}
+
+func (t *Tracing) tracer() trace.Tracer {
+	return t.Provider.Tracer(instrumentationName)
+}
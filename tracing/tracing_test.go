@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"demo-curd/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestTracing(t *testing.T) (*Tracing, *tracetest.SpanRecorder) {
+	t.Helper()
+	var c config.Config
+	c.Tracing.Enabled = true
+	c.Tracing.ServiceName = "demo-curd-test"
+	c.Tracing.SampleRatio = 1
+	recorder := tracetest.NewSpanRecorder()
+	tr := WithProcessor(c, recorder)
+	t.Cleanup(func() {
+		_ = tr.Shutdown(context.Background())
+	})
+	return tr, recorder
+}
+
+// spanNamed returns the ended span called name.
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	var names []string
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+		names = append(names, span.Name())
+	}
+	t.Fatalf("no span %v in %v", name, names)
+	return nil
+}
+
+func TestRequestAndQuerySpans(t *testing.T) {
+	tr, recorder := newTestTracing(t)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err == nil {
+		err = db.Use(tr.GormPlugin())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(otelgin.Middleware("demo-curd-test", otelgin.WithTracerProvider(tr.Provider)))
+	e.GET("/curd/:id", func(c *gin.Context) {
+		var n int64
+		db.WithContext(c.Request.Context()).Table("sqlite_master").Where("type = ?", "table").Count(&n)
+		c.Status(http.StatusNoContent)
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/curd/1", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status %v", w.Code)
+	}
+
+	server := spanNamed(t, recorder, "/curd/:id")
+	if server.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("request span kind %v", server.SpanKind())
+	}
+	query := spanNamed(t, recorder, "gorm.query")
+	if query.SpanKind() != trace.SpanKindClient || query.Parent().SpanID() != server.SpanContext().SpanID() ||
+		query.SpanContext().TraceID() != server.SpanContext().TraceID() {
+		t.Fatalf("query span %v of parent %v, want a client child of %v", query.SpanKind(), query.Parent().SpanID(), server.SpanContext().SpanID())
+	}
+	attributes := make(map[string]string)
+	for _, kv := range query.Attributes() {
+		attributes[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attributes[string(semconv.DBSystemKey)] != "sqlite" || attributes[string(semconv.DBSQLTableKey)] != "sqlite_master" ||
+		attributes[string(semconv.DBStatementKey)] != "SELECT count(*) FROM `sqlite_master` WHERE type = ?" {
+		t.Fatalf("query span attributes %v", attributes)
+	}
+}
+
+func TestAMQPPropagation(t *testing.T) {
+	tr, recorder := newTestTracing(t)
+	ctx, parent := tr.tracer().Start(context.Background(), "request")
+
+	msg := amqp.Publishing{Body: []byte("{}")}
+	_, publish := StartPublish(ctx, "curd", "curd.created", &msg)
+	EndSpan(publish, nil)
+	parent.End()
+	if msg.Headers["traceparent"] == nil {
+		t.Fatalf("headers %v without trace context", msg.Headers)
+	}
+
+	var handled trace.SpanContext
+	Consume("curd.created", func(ctx context.Context, _ amqp.Delivery) {
+		handled = trace.SpanContextFromContext(ctx)
+	})(amqp.Delivery{Headers: msg.Headers, RoutingKey: "curd.created"})
+
+	producer := spanNamed(t, recorder, "curd publish")
+	consumer := spanNamed(t, recorder, "curd.created process")
+	if producer.SpanKind() != trace.SpanKindProducer || producer.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("producer span %v of parent %v", producer.SpanKind(), producer.Parent().SpanID())
+	}
+	if consumer.SpanKind() != trace.SpanKindConsumer || consumer.Parent().SpanID() != producer.SpanContext().SpanID() ||
+		!consumer.Parent().IsRemote() || consumer.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Fatalf("consumer span %v of parent %v, want a child of %v", consumer.SpanKind(), consumer.Parent(), producer.SpanContext())
+	}
+	if handled.SpanID() != consumer.SpanContext().SpanID() {
+		t.Fatalf("handler run in span %v, want %v", handled.SpanID(), consumer.SpanContext().SpanID())
+	}
+}
@@ -1,11 +1,17 @@
 package constant
 
+import "time"
+
 const DefaultPageSize = 10
 const DefaultPage = 1
 const DefaultPageSort = "created_at desc"
 const HeaderAcceptLanguage = "Accept-Language"
+const HeaderContentLanguage = "Content-Language"
+const HeaderVary = "Vary"
 const DefaultLang = "en"
 const DefaultEnv = "PROD"
+const EnvDev = "DEV"
+const EnvLocal = "LOCAL"
 const CharSetUtf8 = "UTF-8"
 const (
 	USER_ID      = "user_id"
@@ -17,8 +23,142 @@ const (
 	BIZAPP_ID    = "bizapp_id"
 )
 
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+const DefaultCharset = "utf8mb4"
+const DefaultSslMode = "disable"
+const DefaultTimezone = "Local"
+
+const DefaultPhoneRegion = "VN"
+
+const DefaultServerPort = "8099"
+const DefaultLogLevel = "info"
+const DefaultSlowQueryThreshold = time.Second
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+const HeaderRequestId = "X-Request-ID"
+
+const DefaultServiceName = "demo-curd"
+const (
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+const TracingShutdownTimeout = 5 * time.Second
+const RequestIdMaxLength = 128
+const DefaultConnMaxLifetime = time.Hour
+const DefaultCorsMaxAge = 12 * time.Hour
+const JwtDefaultSigningAlg = "HS256"
+const JwtDefaultExpiredTime = time.Hour
+const JwtDefaultRefreshExpTime = 24 * time.Hour
+
+const SearchMaxCandidates = 1000
+const SearchMaxTerms = 10
+
+const BulkMaxItems = 5000
+const BulkBatchSize = 500
+
+const ImportMaxFileSize = 50 << 20
+const ImportJobRetention = 24 * time.Hour
+const ImportDefaultDirName = "demo-curd-import"
+const ImportJobType = "curd.import"
+const ImportCleanupJobType = "curd.import.cleanup"
+
+const (
+	ImportStatusPending = "pending"
+	ImportStatusRunning = "running"
+	ImportStatusDone    = "done"
+	ImportStatusFailed  = "failed"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusDone      = "done"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+const JobSchedulerLockName = "job-scheduler"
+const JobDefaultWorkers = 4
+const JobDefaultMaxAttempts = 5
+const JobDefaultPollInterval = time.Second
+const JobDefaultBackoffBase = 10 * time.Second
+const JobDefaultBackoffMax = time.Hour
+const JobDefaultLeaseTimeout = 5 * time.Minute
+const JobDefaultRetention = 7 * 24 * time.Hour
+
+const (
+	WebhookEventCurdCreated = "curd.created"
+	WebhookEventCurdUpdated = "curd.updated"
+	WebhookEventCurdDeleted = "curd.deleted"
+	WebhookEventAll         = "*"
+)
+const WebhookDeliverJobType = "webhook.deliver"
+const WebhookHeaderId = "X-Webhook-Id"
+const WebhookHeaderEvent = "X-Webhook-Event"
+const WebhookHeaderSignature = "X-Webhook-Signature"
+const WebhookDefaultTimeout = 10 * time.Second
+const WebhookDefaultMaxAttempts = 8
+const WebhookDefaultDisableAfter = 20
+
+const (
+	ErrorCodeValidation = "VALIDATION_ERROR"
+	ErrorCodeNotFound   = "NOT_FOUND"
+	ErrorCodeDatabase   = "DATABASE_ERROR"
+	ErrorCodeSkipped    = "SKIPPED"
+	ErrorCodeInternal   = "INTERNAL_ERROR"
+	// the request body can't be applied, e.g. a malformed patch
+	ErrorCodeInvalidRequest   = "INVALID_REQUEST"
+	ErrorCodeUnsupportedMedia = "UNSUPPORTED_MEDIA_TYPE"
+	ErrorCodeConflict         = "CONFLICT"
+	ErrorCodeForbidden        = "FORBIDDEN"
+	// an Idempotency-Key reused with another request, or whose request is still running
+	ErrorCodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	ErrorCodeIdempotencyKeyInUse  = "IDEMPOTENCY_KEY_IN_USE"
+	ErrorCodeRateLimited          = "RATE_LIMITED"
+)
+
+const CacheStoreMemory = "memory"
+const CacheDefaultSize = 10000
+const CacheDefaultTTL = 5 * time.Minute
+const CacheDefaultPrimaryWindow = 10 * time.Second
+const HeaderCacheControl = "Cache-Control"
+const HeaderLastModified = "Last-Modified"
+const HeaderIfModifiedSince = "If-Modified-Since"
+
+// clients may keep the response but must revalidate it before every use
+const CacheControlRevalidate = "private, no-cache"
+
+const (
+	RateLimitKeyIP    = "ip"
+	RateLimitKeyUser  = "user"
+	RateLimitKeyRoute = "route"
+)
+const RateLimitStoreMemory = "memory"
+const RateLimitDefaultPeriod = time.Second
+const HeaderRateLimitLimit = "X-RateLimit-Limit"
+const HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+const HeaderRateLimitReset = "X-RateLimit-Reset"
+const HeaderRetryAfter = "Retry-After"
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+const IdempotencyKeyMaxLength = 255
+
+// bodies of the requests with an Idempotency-Key larger than this are spooled to disk
+const IdempotencyMemoryBodySize = 1 << 20
+const IdempotencyDefaultTTL = 24 * time.Hour
+const IdempotencyCleanupJobType = "idempotency.cleanup"
+
 const ConfigPath = "./config"
 const EnvKey = "ENVIRONMENT"
+const MigrationPath = "./migration/sql"
+const MigrationTable = "schema_migrations"
+const MigrationLockName = "demo_curd_schema_migrations"
 
 type Lang string
 
@@ -28,6 +168,8 @@ const (
 )
 
 const I18nMessage = "messages"
+const I18nDefaultRefreshInterval = time.Minute
+const I18nMessageIdMaxLength = 255
 
 type SecurityAccess string
 
@@ -38,3 +180,6 @@ const (
 	AccessDenyAll       SecurityAccess = "DenyAll"
 	AccessCustom        SecurityAccess = "Custom"
 )
+
+// role of the JWT authorities allowed on /api/v1/admin
+const RoleAdmin = "ADMIN"
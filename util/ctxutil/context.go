@@ -30,3 +30,79 @@ func GetPageFromCtx(ctx context.Context) (page request.Page) {
 	}
 	return page
 }
+
+type langKey struct{}
+
+// ContextWithLang returns a copy of ctx carrying the language of the response, see
+// router.LanguageMiddleware.
+func ContextWithLang(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// GetLangFromCtx returns the language negotiated from the Accept-Language header of the
+// request, or the default language outside of a request.
+func GetLangFromCtx(ctx context.Context) string {
+	if lang, ok := ctx.Value(langKey{}).(string); ok {
+		return lang
+	}
+	return constant.DefaultLang
+}
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the tenant, i.e. the company id of
+// the authenticated user.
+func ContextWithTenant(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantId)
+}
+
+// GetTenantFromCtx returns the tenant of ctx, empty when the request isn't authenticated.
+func GetTenantFromCtx(ctx context.Context) string {
+	tenantId, _ := ctx.Value(tenantKey{}).(string)
+	return tenantId
+}
+
+type userKey struct{}
+
+// ContextWithUser returns a copy of ctx carrying the id of the authenticated user.
+func ContextWithUser(ctx context.Context, userId string) context.Context {
+	return context.WithValue(ctx, userKey{}, userId)
+}
+
+// GetUserFromCtx returns the user id of ctx, empty when the request isn't authenticated.
+func GetUserFromCtx(ctx context.Context) string {
+	userId, _ := ctx.Value(userKey{}).(string)
+	return userId
+}
+
+type authoritiesKey struct{}
+
+// ContextWithAuthorities returns a copy of ctx carrying the roles and permissions of
+// the authenticated user.
+func ContextWithAuthorities(ctx context.Context, authorities []string) context.Context {
+	return context.WithValue(ctx, authoritiesKey{}, authorities)
+}
+
+// HasAuthority reports whether the user of ctx has the role or permission authority.
+func HasAuthority(ctx context.Context, authority string) bool {
+	authorities, _ := ctx.Value(authoritiesKey{}).([]string)
+	for _, a := range authorities {
+		if a == authority {
+			return true
+		}
+	}
+	return false
+}
+
+type requestIdKey struct{}
+
+// ContextWithRequestId returns a copy of ctx carrying the id of the request.
+func ContextWithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// GetRequestIdFromCtx returns the request id of ctx, empty outside of a request.
+func GetRequestIdFromCtx(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
@@ -0,0 +1,120 @@
+package dbutil
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"strings"
+)
+
+// Equal adds a "column = value" condition for every non empty value.
+func Equal(conditions map[string]interface{}) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, column := range sortedKeys(conditions) {
+			if value := conditions[column]; !isEmpty(value) {
+				db = db.Where(clause.Eq{Column: clause.Column{Name: column}, Value: value})
+			}
+		}
+		return db
+	}
+}
+
+// Contains adds a "column LIKE %value%" condition for every non empty value, see ContainsLike.
+func Contains(conditions map[string]string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, column := range sortedKeys(conditions) {
+			if value := conditions[column]; value != "" {
+				db = db.Where(ContainsLike(column, value))
+			}
+		}
+		return db
+	}
+}
+
+// likeEscaper escapes the LIKE wildcards with "!", a backslash would need escaping in
+// the MySQL string literals but not in the PostgreSQL and SQLite ones.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// ContainsLike is a "column LIKE %value%" condition matching value literally, "%" and
+// "_" in value are not wildcards.
+func ContainsLike(column string, value string) clause.Expression {
+	return clause.Expr{
+		SQL:  "? LIKE ? ESCAPE '!'",
+		Vars: []interface{}{clause.Column{Name: column}, "%" + likeEscaper.Replace(value) + "%"},
+	}
+}
+
+// Sort orders by a "column [asc|desc], ..." expression coming from the client. Columns
+// outside allowed are rejected instead of being passed to the query.
+func Sort(expr string, allowed ...string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		columns, err := ParseSort(expr, allowed...)
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		for _, column := range columns {
+			db = db.Order(column)
+		}
+		return db
+	}
+}
+
+var ErrSortInvalid = validation.NewError("validation_sort_invalid", "must be columns among {{.columns}}, each followed by asc or desc")
+
+// ParseSort validates a sort expression and returns its order by clauses, an invalid
+// expression is a validation error of the sort field.
+func ParseSort(expr string, allowed ...string) ([]clause.OrderByColumn, error) {
+	invalid := validation.Errors{"sort": ErrSortInvalid.SetParams(map[string]interface{}{"columns": strings.Join(allowed, ", ")})}
+	var columns []clause.OrderByColumn
+	for _, part := range strings.Split(expr, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 || !contains(allowed, fields[0]) {
+			return nil, invalid
+		}
+		desc := false
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				return nil, invalid
+			}
+		}
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: fields[0]}, Desc: desc})
+	}
+	return columns, nil
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
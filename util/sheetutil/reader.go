@@ -0,0 +1,129 @@
+package sheetutil
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatXLSX   Format = "xlsx"
+	FormatNDJSON Format = "ndjson"
+)
+
+// RowReader streams the rows of a spreadsheet one at a time, so large files are never
+// loaded in memory as a whole.
+type RowReader interface {
+	// Next advances to the next row, it returns false at the end or on error.
+	Next() bool
+	Row() []string
+	Err() error
+	Close() error
+}
+
+// FormatOf detects the format from the file extension.
+func FormatOf(fileName string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	default:
+		return "", fmt.Errorf("unsupported file type: %v, only csv and xlsx are accepted", filepath.Ext(fileName))
+	}
+}
+
+// Open opens path for reading, xlsx files are read from their first sheet.
+func Open(path string, format Format) (RowReader, error) {
+	switch format {
+	case FormatCSV:
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		reader := csv.NewReader(f)
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+		return &csvReader{file: f, reader: reader}, nil
+	case FormatXLSX:
+		f, err := excelize.OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := f.Rows(f.GetSheetName(0))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &xlsxReader{file: f, rows: rows}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %v", format)
+	}
+}
+
+type csvReader struct {
+	file   *os.File
+	reader *csv.Reader
+	row    []string
+	err    error
+}
+
+func (r *csvReader) Next() bool {
+	r.row, r.err = r.reader.Read()
+	if r.err == io.EOF {
+		r.err = nil
+		return false
+	}
+	return r.err == nil
+}
+
+func (r *csvReader) Row() []string {
+	return r.row
+}
+
+func (r *csvReader) Err() error {
+	return r.err
+}
+
+func (r *csvReader) Close() error {
+	return r.file.Close()
+}
+
+type xlsxReader struct {
+	file *excelize.File
+	rows *excelize.Rows
+	row  []string
+	err  error
+}
+
+func (r *xlsxReader) Next() bool {
+	if !r.rows.Next() {
+		r.err = r.rows.Error()
+		return false
+	}
+	r.row, r.err = r.rows.Columns()
+	return r.err == nil
+}
+
+func (r *xlsxReader) Row() []string {
+	return r.row
+}
+
+func (r *xlsxReader) Err() error {
+	return r.err
+}
+
+func (r *xlsxReader) Close() error {
+	if err := r.rows.Close(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,180 @@
+package sheetutil
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RowWriter writes rows one at a time to an underlying writer. Close must be called
+// to flush the buffered rows.
+type RowWriter interface {
+	Write(values []interface{}) error
+	Close() error
+}
+
+// ParseFormat parses an export format, csv is the default.
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatXLSX, FormatNDJSON:
+		return Format(format), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %v, expected csv, ndjson or xlsx", format)
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// NewWriter writes a header row of headers then the rows. ndjson has no header row,
+// each row is written as an object keyed by keys.
+func NewWriter(w io.Writer, format Format, keys []string, headers []string) (RowWriter, error) {
+	switch format {
+	case FormatCSV:
+		writer := &csvWriter{writer: csv.NewWriter(w)}
+		if err := writer.writer.Write(headers); err != nil {
+			return nil, err
+		}
+		return writer, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{writer: bufio.NewWriter(w), keys: keys}, nil
+	case FormatXLSX:
+		file := excelize.NewFile()
+		stream, err := file.NewStreamWriter(file.GetSheetName(0))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		writer := &xlsxWriter{out: w, file: file, stream: stream, row: 1}
+		cells := make([]interface{}, len(headers))
+		for i, header := range headers {
+			cells[i] = header
+		}
+		if err := writer.Write(cells); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return writer, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %v", format)
+	}
+}
+
+// escapeFormula prefixes with ' the texts a spreadsheet would run as a formula, i.e.
+// starting with = + - @ or a tab or carriage return, numbers like a +84 phone excepted.
+func escapeFormula(text string) string {
+	if text == "" || !strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return text
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return text
+	}
+	return "'" + text
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func (r *csvWriter) Write(values []interface{}) error {
+	r.record = r.record[:0]
+	for _, v := range values {
+		if t, ok := v.(time.Time); ok {
+			r.record = append(r.record, t.Format(time.RFC3339))
+			continue
+		}
+		if text, ok := v.(string); ok {
+			v = escapeFormula(text)
+		}
+		r.record = append(r.record, fmt.Sprint(v))
+	}
+	return r.writer.Write(r.record)
+}
+
+func (r *csvWriter) Close() error {
+	r.writer.Flush()
+	return r.writer.Error()
+}
+
+type ndjsonWriter struct {
+	writer *bufio.Writer
+	keys   []string
+}
+
+// Write keeps the order of keys, which a map would lose.
+func (r *ndjsonWriter) Write(values []interface{}) error {
+	r.writer.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			r.writer.WriteByte(',')
+		}
+		key, err := json.Marshal(r.keys[i])
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		r.writer.Write(key)
+		r.writer.WriteByte(':')
+		r.writer.Write(value)
+	}
+	r.writer.WriteByte('}')
+	_, err := r.writer.WriteString("\n")
+	return err
+}
+
+func (r *ndjsonWriter) Close() error {
+	return r.writer.Flush()
+}
+
+// xlsxWriter streams the rows to a temporary file kept by excelize, the workbook is
+// copied to out on Close since a zip archive can't be written incrementally.
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func (r *xlsxWriter) Write(values []interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(1, r.row)
+	if err != nil {
+		return err
+	}
+	r.row++
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		if text, ok := v.(string); ok {
+			v = escapeFormula(text)
+		}
+		cells[i] = v
+	}
+	return r.stream.SetRow(cell, cells)
+}
+
+func (r *xlsxWriter) Close() error {
+	defer r.file.Close()
+	if err := r.stream.Flush(); err != nil {
+		return err
+	}
+	return r.file.Write(r.out)
+}
@@ -0,0 +1,36 @@
+package sheetutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCSVWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, []string{"name", "phone"}, []string{"name", "phone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{
+		{"=HYPERLINK(\"http://evil\")", "+84912345678"},
+		{"@SUM(A1)", "-1"},
+		{"+1+cmd|' /C calc'!A0", 42},
+		{"Nam", "\tx"},
+	}
+	for _, row := range rows {
+		if err = w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "name,phone\n" +
+		"\"'=HYPERLINK(\"\"http://evil\"\")\",+84912345678\n" +
+		"'@SUM(A1),-1\n" +
+		"'+1+cmd|' /C calc'!A0,42\n" +
+		"Nam,'\tx\n"
+	if buf.String() != want {
+		t.Fatalf("got\n%q\nwant\n%q", buf.String(), want)
+	}
+}
@@ -0,0 +1,113 @@
+package textutil
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize lower cases s and strips its diacritics, so "Hà Nội" and "Ha Noi" are
+// both "ha noi". đ is not a combining mark in Unicode and is mapped to d explicitly.
+func Normalize(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		b.WriteString(normalizeRune(r))
+	}
+	return b.String()
+}
+
+func normalizeRune(r rune) string {
+	switch r {
+	case 'đ', 'Đ':
+		return "d"
+	}
+	var b strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(d))
+	}
+	return b.String()
+}
+
+// Terms splits a search query into normalized terms, duplicates are removed.
+func Terms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, term := range strings.FieldsFunc(Normalize(query), func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`"'+-*~<>()`, r)
+	}) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Highlight html escapes s and wraps the fragments matching terms in <em> tags, the
+// matching ignores case and diacritics. It returns "" when nothing matches.
+func Highlight(s string, terms []string) string {
+	// normalized text and, for each of its bytes, the index of the original rune
+	var normalized strings.Builder
+	var origins []int
+	runes := []rune(s)
+	for i, r := range runes {
+		n := normalizeRune(r)
+		normalized.WriteString(n)
+		for j := 0; j < len(n); j++ {
+			origins = append(origins, i)
+		}
+	}
+	text := normalized.String()
+
+	// matched ranges of original runes, [start, end)
+	var ranges [][2]int
+	for _, term := range terms {
+		for from := 0; term != "" && from < len(text); {
+			i := strings.Index(text[from:], term)
+			if i < 0 {
+				break
+			}
+			start, end := from+i, from+i+len(term)
+			ranges = append(ranges, [2]int{origins[start], origins[end-1] + 1})
+			from = end
+		}
+	}
+	if len(ranges) == 0 {
+		return ""
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+
+	// merge the overlapping and adjacent ranges
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1] {
+			if r[1] > last[1] {
+				last[1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, r := range merged {
+		b.WriteString(html.EscapeString(string(runes[pos:r[0]])))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(string(runes[r[0]:r[1]])))
+		b.WriteString("</em>")
+		pos = r[1]
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:])))
+	return b.String()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"demo-curd/dto/response"
+	"demo-curd/model"
+	"demo-curd/service"
+	"demo-curd/util/constant"
+	"demo-curd/util/ctxutil"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the deliveries it's sent and answers them with status.
+type webhookReceiver struct {
+	*httptest.Server
+	status int
+	mu     sync.Mutex
+	got    []webhookRequest
+}
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+func newWebhookReceiver(t *testing.T, status int) *webhookReceiver {
+	r := &webhookReceiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.got = append(r.got, webhookRequest{header: req.Header, body: body, at: time.Now()})
+		r.mu.Unlock()
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// wait returns the deliveries once n were received.
+func (r *webhookReceiver) wait(t *testing.T, n int) []webhookRequest {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		r.mu.Lock()
+		got := append([]webhookRequest(nil), r.got...)
+		r.mu.Unlock()
+		if len(got) >= n {
+			return got
+		}
+	}
+	t.Fatalf("%v deliveries expected", n)
+	return nil
+}
+
+// newWebhookTestApp returns an app running its jobs with a short backoff, allowed to
+// deliver to the local receivers.
+func newWebhookTestApp(t *testing.T, maxAttempts int, disableAfter int) *testApp {
+	t.Setenv("APP_WEBHOOK_ALLOWPRIVATENETWORKS", "true")
+	t.Setenv("APP_WEBHOOK_MAXATTEMPTS", strconv.Itoa(maxAttempts))
+	t.Setenv("APP_WEBHOOK_DISABLEAFTER", strconv.Itoa(disableAfter))
+	t.Setenv("APP_JOB_POLLINTERVAL", "10ms")
+	t.Setenv("APP_JOB_BACKOFFBASE", "100ms")
+	t.Setenv("APP_JOB_BACKOFFMAX", "1s")
+	app := newTestApp(t)
+	app.JobManager.Start()
+	t.Cleanup(app.JobManager.Stop)
+	return app
+}
+
+func (a *testApp) subscribe(url string) response.WebhookSubscriptionDTO {
+	a.t.Helper()
+	var subscription response.WebhookSubscriptionDTO
+	a.decode(a.do(http.MethodPost, "/api/v1/webhooks", "application/json",
+		map[string]interface{}{"url": url, "event_types": []string{constant.WebhookEventCurdCreated}}),
+		http.StatusOK, &subscription)
+	return subscription
+}
+
+func (a *testApp) createCurd(name string) response.CurdDTO {
+	a.t.Helper()
+	var curd response.CurdDTO
+	a.decode(a.do(http.MethodPost, "/api/v1/curd", "application/json",
+		map[string]string{"name": name, "email": strings.ToLower(name) + "@example.com"}),
+		http.StatusOK, &curd)
+	return curd
+}
+
+func TestWebhookDeliverySignature(t *testing.T) {
+	app := newWebhookTestApp(t, 3, 10)
+	receiver := newWebhookReceiver(t, http.StatusNoContent)
+	subscription := app.subscribe(receiver.URL)
+	curd := app.createCurd("Nam")
+
+	delivery := receiver.wait(t, 1)[0]
+	var timestamp int64
+	if _, err := fmt.Sscanf(delivery.header.Get(constant.WebhookHeaderSignature), "t=%d,", &timestamp); err != nil {
+		t.Fatalf("signature %q: %v", delivery.header.Get(constant.WebhookHeaderSignature), err)
+	}
+	if want := service.Sign(subscription.Secret, time.Unix(timestamp, 0), delivery.body); delivery.header.Get(constant.WebhookHeaderSignature) != want {
+		t.Fatalf("signature %q, want %q", delivery.header.Get(constant.WebhookHeaderSignature), want)
+	}
+	if service.Sign("other secret", time.Unix(timestamp, 0), delivery.body) == delivery.header.Get(constant.WebhookHeaderSignature) {
+		t.Fatal("signature doesn't depend on the secret")
+	}
+
+	var event response.WebhookEventDTO
+	if err := json.Unmarshal(delivery.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	var data response.CurdDTO
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != constant.WebhookEventCurdCreated || event.TenantId != "1" || data.Id != curd.Id ||
+		delivery.header.Get(constant.WebhookHeaderId) != event.Id || delivery.header.Get(constant.WebhookHeaderEvent) != event.Type {
+		t.Fatalf("event %+v %+v, headers %v", event, data, delivery.header)
+	}
+}
+
+func TestWebhookDeliveryRetriesAndDisables(t *testing.T) {
+	const disableAfter = 3
+	app := newWebhookTestApp(t, 5, disableAfter)
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError)
+	subscription := app.subscribe(receiver.URL)
+	app.createCurd("Nam")
+
+	got := receiver.wait(t, disableAfter)
+	// the job backoff doubles from 100ms between the attempts of the event
+	for i, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+		if d := got[i+1].at.Sub(got[i].at); d < want {
+			t.Fatalf("attempt %v retried after %v, want %v", i+2, d, want)
+		}
+	}
+
+	path := fmt.Sprintf("/api/v1/webhooks/%v", subscription.Id)
+	for deadline := time.Now().Add(5 * time.Second); subscription.Active; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription still active: %+v", subscription)
+		}
+		app.decode(app.do(http.MethodGet, path, "", nil), http.StatusOK, &subscription)
+	}
+	if subscription.FailureCount != disableAfter || subscription.DisabledAt == nil {
+		t.Fatalf("disabled subscription %+v", subscription)
+	}
+
+	// the disabled subscription ends the retries of the event and gets no new ones
+	app.createCurd("Lan")
+	time.Sleep(500 * time.Millisecond)
+	if got = receiver.wait(t, disableAfter); len(got) != disableAfter {
+		t.Fatalf("%v deliveries to a disabled subscription, want %v", len(got), disableAfter)
+	}
+	var deliveries []response.WebhookDeliveryDTO
+	app.decode(app.do(http.MethodGet, path+"/deliveries", "", nil), http.StatusOK, &response.PageDTO{Items: &deliveries})
+	if len(deliveries) != disableAfter {
+		t.Fatalf("logged deliveries %+v", deliveries)
+	}
+	for _, delivery := range deliveries {
+		if delivery.Success || delivery.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("logged delivery %+v", delivery)
+		}
+	}
+}
+
+func TestWebhookEventsGoToTheCurdTenant(t *testing.T) {
+	app := newWebhookTestApp(t, 1, 10)
+	other := newWebhookReceiver(t, http.StatusNoContent)
+	app.login(2)
+	app.subscribe(other.URL)
+	receiver := newWebhookReceiver(t, http.StatusNoContent)
+	app.login(1)
+	app.subscribe(receiver.URL)
+
+	// published while acting for company 2, the curd is of company 1
+	ctx := ctxutil.ContextWithTenant(context.Background(), "2")
+	curd := model.Curd{TenantId: "1", Name: "Nam"}
+	err := app.WebhookV1Api.WebhookService.Publish(ctx, constant.WebhookEventCurdCreated, service.WebhookEvent{TenantId: curd.TenantId, Data: curd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event response.WebhookEventDTO
+	if err = json.Unmarshal(receiver.wait(t, 1)[0].body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.TenantId != "1" {
+		t.Fatalf("event %+v", event)
+	}
+	time.Sleep(200 * time.Millisecond)
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	if len(other.got) != 0 {
+		t.Fatalf("event of company 1 delivered to company 2: %s", other.got[0].body)
+	}
+}
@@ -4,12 +4,16 @@ package main
 
 import (
 	v1 "demo-curd/api/v1"
+	"demo-curd/cache"
 	"demo-curd/config"
 	"demo-curd/dao"
 	"demo-curd/database"
 	"demo-curd/i18n"
+	"demo-curd/job"
+	"demo-curd/migration"
 	"demo-curd/router"
 	"demo-curd/service"
+	"demo-curd/tracing"
 	"github.com/google/wire"
 )
 
@@ -17,16 +21,66 @@ func InitApp() (App, error) {
 	panic(wire.Build(
 		// infrastructure
 		config.LoadConfig,
+		config.NewWatcher,
+		tracing.NewTracing,
 		database.NewDatabase,
+		database.NewDatasources,
 		i18n.NewI18n,
 		router.NewRouterWithoutAuthMw,
+		router.NewIdempotencyMiddleware,
+		router.NewRateLimitStore,
+		router.NewRateLimiter,
+		wire.Bind(new(router.IdempotencyStore), new(*dao.IdempotencyKeyDao)),
+		wire.Bind(new(i18n.MessageStore), new(*dao.TranslationDao)),
+		migration.NewMigrator,
+		job.NewManager,
+		cache.NewCache,
 		// dao
-		wire.Struct(new(dao.CurdDao), "*"),
+		dao.NewCurdDao,
+		dao.NewCachedCurdDao,
+		dao.NewCurdSearcher,
+		dao.NewJobDao,
+		dao.NewWebhookSubscriptionDao,
+		dao.NewWebhookDeliveryDao,
+		dao.NewIdempotencyKeyDao,
+		dao.NewTranslationDao,
+		dao.NewCurdImportDao,
 		//service
+		wire.Struct(new(service.TxManager), "*"),
 		wire.Struct(new(service.CurdService), "*"),
+		service.NewCurdValidator,
+		service.NewCurdImportService,
+		wire.Struct(new(service.CurdExportService), "*"),
+		wire.Struct(new(service.CurdSearchService), "*"),
+		service.NewJobHandlers,
+		wire.Struct(new(service.JobService), "*"),
+		service.NewWebhookService,
+		service.NewWebhookDeliveryService,
+		wire.Struct(new(service.TranslationService), "*"),
 		// api
 		wire.Struct(new(v1.CurdV1Api), "*"),
+		wire.Struct(new(v1.JobV1Api), "*"),
+		wire.Struct(new(v1.WebhookV1Api), "*"),
+		wire.Struct(new(v1.I18nV1Api), "*"),
 		// app
 		wire.Struct(new(App), "*")))
 	return App{}, nil
 }
+
+func InitMigrator() (*migration.Migrator, error) {
+	panic(wire.Build(
+		config.LoadConfig,
+		tracing.NewTracing,
+		database.NewDatabase,
+		migration.NewMigrator))
+	return nil, nil
+}
+
+func InitCurdDao() (*dao.CurdDao, error) {
+	panic(wire.Build(
+		config.LoadConfig,
+		tracing.NewTracing,
+		database.NewDatabase,
+		dao.NewCurdDao))
+	return nil, nil
+}
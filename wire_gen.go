@@ -1,18 +1,23 @@
 // Code generated by Wire. DO NOT EDIT.
 
 //go:generate go run github.com/google/wire/cmd/wire
-//+build !wireinject
+//go:build !wireinject
+// +build !wireinject
 
 package main
 
 import (
 	"demo-curd/api/v1"
+	"demo-curd/cache"
 	"demo-curd/config"
 	"demo-curd/dao"
 	"demo-curd/database"
 	"demo-curd/i18n"
+	"demo-curd/job"
+	"demo-curd/migration"
 	"demo-curd/router"
 	"demo-curd/service"
+	"demo-curd/tracing"
 )
 
 // Injectors from wire.go:
@@ -22,33 +27,159 @@ func InitApp() (App, error) {
 	if err != nil {
 		return App{}, err
 	}
-	databaseDatabase, err := database.NewDatabase(configConfig)
+	watcher := config.NewWatcher(configConfig)
+	tracingTracing, err := tracing.NewTracing(configConfig)
 	if err != nil {
 		return App{}, err
 	}
-	i18nI18n, err := i18n.NewI18n(configConfig)
+	databaseDatabase, err := database.NewDatabase(configConfig, tracingTracing)
 	if err != nil {
 		return App{}, err
 	}
-	routerRouter, err := router.NewRouterWithoutAuthMw(configConfig, i18nI18n)
+	datasources, err := database.NewDatasources(configConfig, tracingTracing)
 	if err != nil {
 		return App{}, err
 	}
-	curdDao := &dao.CurdDao{
+	translationDao := dao.NewTranslationDao(databaseDatabase)
+	i18nI18n, err := i18n.NewI18n(configConfig, translationDao)
+	if err != nil {
+		return App{}, err
+	}
+	routerRouter, err := router.NewRouterWithoutAuthMw(configConfig, i18nI18n, tracingTracing)
+	if err != nil {
+		return App{}, err
+	}
+	migrator, err := migration.NewMigrator(databaseDatabase)
+	if err != nil {
+		return App{}, err
+	}
+	jobDao := dao.NewJobDao(databaseDatabase)
+	idempotencyKeyDao := dao.NewIdempotencyKeyDao(databaseDatabase)
+	curdImportDao := dao.NewCurdImportDao(databaseDatabase)
+	webhookSubscriptionDao := dao.NewWebhookSubscriptionDao(databaseDatabase)
+	webhookDeliveryDao := dao.NewWebhookDeliveryDao(databaseDatabase)
+	webhookDeliveryService := service.NewWebhookDeliveryService(configConfig, webhookSubscriptionDao, webhookDeliveryDao)
+	v := service.NewJobHandlers(configConfig, jobDao, idempotencyKeyDao, curdImportDao, webhookDeliveryService)
+	manager, err := job.NewManager(configConfig, jobDao, v)
+	if err != nil {
+		return App{}, err
+	}
+	idempotencyMiddleware := router.NewIdempotencyMiddleware(configConfig, idempotencyKeyDao, i18nI18n)
+	rateLimitStore, err := router.NewRateLimitStore(configConfig)
+	if err != nil {
+		return App{}, err
+	}
+	rateLimiter := router.NewRateLimiter(configConfig, rateLimitStore, i18nI18n)
+	curdDao := dao.NewCurdDao(databaseDatabase)
+	cacheCache, err := cache.NewCache(configConfig)
+	if err != nil {
+		return App{}, err
+	}
+	cachedCurdDao := dao.NewCachedCurdDao(configConfig, curdDao, cacheCache)
+	txManager := &service.TxManager{
 		Db: databaseDatabase,
 	}
+	webhookService := service.NewWebhookService(configConfig, webhookSubscriptionDao, webhookDeliveryDao, manager)
+	curdValidator := service.NewCurdValidator(configConfig, curdDao)
 	curdService := &service.CurdService{
+		CurdDao:        curdDao,
+		CachedCurdDao:  cachedCurdDao,
+		TxManager:      txManager,
+		WebhookService: webhookService,
+		CurdValidator:  curdValidator,
+	}
+	curdImportService, err := service.NewCurdImportService(configConfig, curdDao, cachedCurdDao, curdImportDao, curdValidator, txManager, webhookService, manager)
+	if err != nil {
+		return App{}, err
+	}
+	curdExportService := &service.CurdExportService{
 		CurdDao: curdDao,
+		I18n:    i18nI18n,
+	}
+	curdSearcher := dao.NewCurdSearcher(databaseDatabase)
+	curdSearchService := &service.CurdSearchService{
+		CurdSearcher: curdSearcher,
 	}
 	curdV1Api := &v1.CurdV1Api{
-		CurdService: curdService,
+		CurdService:       curdService,
+		CurdImportService: curdImportService,
+		CurdExportService: curdExportService,
+		CurdSearchService: curdSearchService,
+		I18n:              i18nI18n,
+	}
+	jobService := &service.JobService{
+		JobDao:     jobDao,
+		JobManager: manager,
+	}
+	jobV1Api := &v1.JobV1Api{
+		JobService: jobService,
+		I18n:       i18nI18n,
+	}
+	webhookV1Api := &v1.WebhookV1Api{
+		WebhookService: webhookService,
+	}
+	translationService := &service.TranslationService{
+		TxManager:      txManager,
+		TranslationDao: translationDao,
+		I18n:           i18nI18n,
+	}
+	i18nV1Api := &v1.I18nV1Api{
+		I18n:               i18nI18n,
+		TranslationService: translationService,
 	}
 	app := App{
-		Config:    configConfig,
-		Database:  databaseDatabase,
-		Router:    routerRouter,
-		I18n:      i18nI18n,
-		CurdV1Api: curdV1Api,
+		Config:        configConfig,
+		ConfigWatcher: watcher,
+		Tracing:       tracingTracing,
+		Database:      databaseDatabase,
+		Datasources:   datasources,
+		Router:        routerRouter,
+		I18n:          i18nI18n,
+		Migrator:      migrator,
+		JobManager:    manager,
+		Idempotency:   idempotencyMiddleware,
+		RateLimiter:   rateLimiter,
+		CurdV1Api:     curdV1Api,
+		JobV1Api:      jobV1Api,
+		WebhookV1Api:  webhookV1Api,
+		I18nV1Api:     i18nV1Api,
 	}
 	return app, nil
 }
+
+func InitMigrator() (*migration.Migrator, error) {
+	configConfig, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	tracingTracing, err := tracing.NewTracing(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	databaseDatabase, err := database.NewDatabase(configConfig, tracingTracing)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migration.NewMigrator(databaseDatabase)
+	if err != nil {
+		return nil, err
+	}
+	return migrator, nil
+}
+
+func InitCurdDao() (*dao.CurdDao, error) {
+	configConfig, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	tracingTracing, err := tracing.NewTracing(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	databaseDatabase, err := database.NewDatabase(configConfig, tracingTracing)
+	if err != nil {
+		return nil, err
+	}
+	curdDao := dao.NewCurdDao(databaseDatabase)
+	return curdDao, nil
+}